@@ -12,20 +12,36 @@ import (
 	"os"
 	"runtime"
 	"runtime/debug"
+	"strconv"
 	"strings"
 
+	"stefanco.de/bookprint/internal/book"
 	"stefanco.de/bookprint/internal/bookprint"
+	"stefanco.de/bookprint/internal/project"
 	"stefanco.de/bookprint/internal/util/fs"
+	"stefanco.de/bookprint/internal/util/slices"
 )
 
 const usage = `
 Usage:
-	bookprint [options...] <file>
+	bookprint [options...] <file|dir|pattern>...
+	bookprint serve [options...] <file|dir|pattern>...
+	bookprint check [options...]
+	bookprint init [dir]
 
 Options:
-	-t, --template-dir <dir>    Path to the directory containing custom templates used for generating the book.
+	-t, --template-dir <dir>    Path to the directory containing custom templates used for generating the book. Defaults to the embedded theme.
 	-o, --output-dir <dir>      Path to the directory where the generated book pages will be stored.
 	-s, --static-dir <dir>      Path to the directory with additional files for the book. Copied to output directory.
+	-f, --format <formats>      Comma-separated list of output formats: html, epub, json. Defaults to html.
+	-i, --input-format <format> Format of the input files: html or markdown. Detected by file extension, if omitted.
+	-l, --split-level <level>   Headings up to this level start a new page, e.g. 2 for h1 and h2. Defaults to 6.
+	    --paths <strategy>      File names of the pages: numeric, slug, id or a pattern like '{{.Prefix}}-{{.Slug}}.html'. Defaults to numeric.
+	    --layout <layout>       Layout of the pages: flat, or nested for a directory per page within its parent's directory. Defaults to flat.
+	    --strict                Fail on broken or ambiguous cross-references, duplicate titles and untranslated pages.
+	-w, --watch                 Keep running and rebuild the book when the input files, templates or static files change.
+	    --port <port>           Port of the preview server of 'bookprint serve'. Defaults to 8080.
+	-p, --project <file>        Path to the project file. Defaults to 'bookprint.json' or 'bookprint.toml' in the working directory.
 	-v, --version               Print the version number.
 	-h, --help                  Print the help message.
 
@@ -34,6 +50,34 @@ Examples:
 	$ bookprint --template-dir templates --output-dir out examples/index.html
 	> Created book in 'out' directory
 
+	Creating a website and an e-book:
+	$ bookprint --format html,epub --template-dir templates --output-dir out examples/index.html
+	> Created book in 'out' directory
+
+	Reading from Markdown file:
+	$ bookprint --template-dir templates --output-dir out examples/book.md
+	> Created book in 'out' directory
+
+	Reading one file per chapter from a directory, ordered by 'manifest.json' or by file name:
+	$ bookprint --template-dir templates --output-dir out examples/chapters
+	> Created book in 'out' directory
+
+	Reading the settings from the project file 'bookprint.json' in the working directory:
+	$ bookprint
+	> Created book in 'out' directory
+
+	Creating a new project with the default theme, a sample book and a project file:
+	$ bookprint init my-book
+	> Created project in 'my-book' directory
+
+	Previewing the book with live reload:
+	$ bookprint serve examples/book.md
+	> Serving book at http://localhost:8080/
+
+	Checking the templates against a sample book:
+	$ bookprint check --template-dir templates
+	> Checked 5 templates without problems
+
 	Reading from STDIN:
 	$ echo "<html>...</html>" | bookprint --template-dir templates --output-dir out --
 	> Created book in 'out' directory
@@ -47,34 +91,96 @@ Examples:
 // See: Dockerfile
 var Version string
 
+// flagNames maps the short names of the flags to their long names,
+// which are also the keys of the project file.
+var flagNames = map[string]string{
+	"t": "template-dir",
+	"s": "static-dir",
+	"o": "output-dir",
+	"f": "format",
+	"i": "input-format",
+	"l": "split-level",
+	"w": "watch",
+	"p": "project",
+}
+
 func main() {
 	flag.Usage = getUsage
 
-	if len(os.Args) == 1 {
-		flag.Usage()
-		os.Exit(1)
-	}
-
 	var (
 		templateDirectoryFlag string
 		outputDirectoryFlag   string
 		staticDirectoryFlag   string
+		formatFlag            string
+		inputFormatFlag       string
+		splitLevelFlag        int
+		pathsFlag             string
+		layoutFlag            string
+		strictFlag            bool
+		watchFlag             bool
+		portFlag              int
+		projectFlag           string
 		versionFlag           bool
 		helpFlag              bool
 	)
 
-	flag.StringVar(&templateDirectoryFlag, "t", "templates", "Path to the directory containing custom templates used for generating the book.")
-	flag.StringVar(&templateDirectoryFlag, "template-dir", "templates", "Path to the directory containing custom templates used for generating the book.")
+	flag.StringVar(&templateDirectoryFlag, "t", "", "Path to the directory containing custom templates used for generating the book. Defaults to the embedded theme.")
+	flag.StringVar(&templateDirectoryFlag, "template-dir", "", "Path to the directory containing custom templates used for generating the book. Defaults to the embedded theme.")
 	flag.StringVar(&staticDirectoryFlag, "s", "", "Path to the directory with additional files for the book. Copied to output directory.")
 	flag.StringVar(&staticDirectoryFlag, "static-dir", "", "Path to the directory with additional files for the book. Copied to output directory.")
 	flag.StringVar(&outputDirectoryFlag, "o", "out", "Path to the directory where the generated book pages will be stored.")
 	flag.StringVar(&outputDirectoryFlag, "output-dir", "out", "Path to the directory where the generated book pages will be stored.")
+	flag.StringVar(&formatFlag, "f", bookprint.DefaultFormat, "Comma-separated list of output formats: html, epub, json. Defaults to html.")
+	flag.StringVar(&formatFlag, "format", bookprint.DefaultFormat, "Comma-separated list of output formats: html, epub, json. Defaults to html.")
+	flag.StringVar(&inputFormatFlag, "i", "", "Format of the input files: html or markdown. Detected by file extension, if omitted.")
+	flag.StringVar(&inputFormatFlag, "input-format", "", "Format of the input files: html or markdown. Detected by file extension, if omitted.")
+	flag.IntVar(&splitLevelFlag, "l", book.DefaultSplitLevel, "Headings up to this level start a new page, e.g. 2 for h1 and h2. Defaults to 6.")
+	flag.IntVar(&splitLevelFlag, "split-level", book.DefaultSplitLevel, "Headings up to this level start a new page, e.g. 2 for h1 and h2. Defaults to 6.")
+	flag.StringVar(&pathsFlag, "paths", book.NumericPaths, "File names of the pages: numeric, slug, id or a pattern like '{{.Prefix}}-{{.Slug}}.html'. Defaults to numeric.")
+	flag.StringVar(&layoutFlag, "layout", book.FlatLayout, "Layout of the pages: flat, or nested for a directory per page within its parent's directory. Defaults to flat.")
+	flag.BoolVar(&strictFlag, "strict", false, "Fail on broken or ambiguous cross-references, duplicate titles and untranslated pages.")
+	flag.BoolVar(&watchFlag, "w", false, "Keep running and rebuild the book when the input files, templates or static files change.")
+	flag.BoolVar(&watchFlag, "watch", false, "Keep running and rebuild the book when the input files, templates or static files change.")
+	flag.IntVar(&portFlag, "port", 8080, "Port of the preview server of 'bookprint serve'. Defaults to 8080.")
+	flag.StringVar(&projectFlag, "p", "", "Path to the project file. Defaults to 'bookprint.json' or 'bookprint.toml' in the working directory.")
+	flag.StringVar(&projectFlag, "project", "", "Path to the project file. Defaults to 'bookprint.json' or 'bookprint.toml' in the working directory.")
 	flag.BoolVar(&versionFlag, "v", false, "Print the version number.")
 	flag.BoolVar(&versionFlag, "version", false, "Print the version number.")
 	flag.BoolVar(&helpFlag, "h", false, "Print the help message.")
 	flag.BoolVar(&helpFlag, "help", false, "Print the help message.")
 
-	flag.Parse()
+	arguments := os.Args[1:]
+
+	// The "init" command writes the default theme, a sample and a project file.
+	if len(arguments) > 0 && arguments[0] == "init" {
+		directory := "."
+		if len(arguments) > 1 {
+			directory = arguments[1]
+		}
+
+		err := initProject(directory)
+		if err != nil {
+			fail(err)
+		}
+
+		fmt.Printf("Created project in '%s' directory", directory)
+		os.Exit(0)
+	}
+
+	// The "serve" command builds the book into a temporary directory and serves it,
+	// the "check" command checks the templates against a sample book.
+	command := ""
+	if len(arguments) > 0 && (arguments[0] == "serve" || arguments[0] == "check") {
+		command = arguments[0]
+		arguments = arguments[1:]
+	}
+	serveCommand := command == "serve"
+	checkCommand := command == "check"
+
+	err := flag.CommandLine.Parse(arguments)
+	if err != nil {
+		fail(err)
+	}
 
 	if helpFlag {
 		flag.Usage()
@@ -86,44 +192,149 @@ func main() {
 		os.Exit(0)
 	}
 
-	file, err := getFile(flag.Arg(0))
+	p, err := getProject(projectFlag)
 	if err != nil {
 		fail(err)
 	}
 
-	// Missing template directory
-	if !fs.ExistDir(templateDirectoryFlag) {
-		fail(fmt.Errorf("directory '%s' does not exist", templateDirectoryFlag))
+	// Without arguments and project file there is nothing to do.
+	if len(os.Args) == 1 && p.Name == "" {
+		flag.Usage()
+		os.Exit(1)
 	}
 
-	// Create output directory
-	err = fs.RemoveDir(outputDirectoryFlag)
+	// Explicitly set flags override the values of the project file,
+	// which override the default values of the flags.
+	setFlags := getSetFlags()
+
+	templateDirectory := getSetting(p, setFlags, "template-dir", templateDirectoryFlag, p.TemplateDir)
+	outputDirectory := getSetting(p, setFlags, "output-dir", outputDirectoryFlag, p.OutputDir)
+	staticDirectory := getSetting(p, setFlags, "static-dir", staticDirectoryFlag, p.StaticDir)
+	inputFormat := getSetting(p, setFlags, "input-format", inputFormatFlag, p.InputFormat)
+	formats := getFormats(getSetting(p, setFlags, "format", formatFlag, strings.Join(p.Formats, ",")))
+
+	splitLevel, err := strconv.Atoi(getSetting(p, setFlags, "split-level", strconv.Itoa(splitLevelFlag), formatInt(p.SplitLevel)))
 	if err != nil {
 		fail(err)
 	}
-	err = fs.MakeDir(outputDirectoryFlag)
+
+	paths := getSetting(p, setFlags, "paths", pathsFlag, p.Paths)
+	layout := getSetting(p, setFlags, "layout", layoutFlag, p.Layout)
+
+	strict := strictFlag || p.Strict
+	if setFlags["strict"] {
+		strict = strictFlag
+	}
+
+	inputs := flag.Args()
+	languages := p.Languages
+	if len(inputs) == 0 {
+		inputs = p.Inputs
+	} else if len(p.Inputs) > 0 {
+		printNote("arguments '%s' override 'inputs' of project file '%s'", strings.Join(inputs, " "), p.Name)
+	} else if len(p.Languages) > 0 {
+		printNote("arguments '%s' override 'languages' of project file '%s'", strings.Join(inputs, " "), p.Name)
+		languages = nil
+	}
+
+	src := &sources{inputs: inputs, languages: languages, format: inputFormat}
+
+	err = bookprint.CheckFormats(formats)
 	if err != nil {
 		fail(err)
 	}
 
-	// Copy everything from static to output directory
-	if staticDirectoryFlag != "" {
-		err := fs.CopyDir(staticDirectoryFlag, outputDirectoryFlag)
+	err = bookprint.CheckOptions(p.Options)
+	if err != nil {
+		fail(fmt.Errorf("invalid project file '%s': %w", p.Name, err))
+	}
+
+	var numbering *book.Numbering
+	if p.Numbering != nil {
+		numbering = &book.Numbering{
+			Depth:    p.Numbering.Depth,
+			Schemes:  p.Numbering.Schemes,
+			Appendix: p.Numbering.Appendix,
+		}
+
+		err = numbering.Check()
+		if err != nil {
+			fail(fmt.Errorf("invalid project file '%s': %w", p.Name, err))
+		}
+	}
+
+	// Watch mode reads the input files on every build, STDIN only once.
+	watching := watchFlag || serveCommand
+	if watching && len(src.paths()) == 0 {
+		fail(fmt.Errorf("watch mode requires input files"))
+	}
+
+	// Serve from a temporary output directory
+	if serveCommand {
+		outputDirectory, err = os.MkdirTemp("", "bookprint-")
 		if err != nil {
 			fail(err)
 		}
 	}
 
-	err = bookprint.New(&bookprint.Config{
-		File:        file,
-		OutputDir:   outputDirectoryFlag,
-		TemplateDir: templateDirectoryFlag,
-	})
+	config := &bookprint.Config{
+		OutputDir:   outputDirectory,
+		TemplateDir: templateDirectory,
+		StaticDir:   staticDirectory,
+		Formats:     formats,
+		SplitLevel:  splitLevel,
+		Numbering:   numbering,
+		Paths:       paths,
+		Layout:      layout,
+		MetaData: &book.MetaData{
+			Title:        p.MetaData.Title,
+			Author:       p.MetaData.Author,
+			Contributors: getContributors(p.MetaData.Contributors),
+			Date:         book.ParseDate(p.MetaData.Date),
+			Published:    book.ParseDate(p.MetaData.Published),
+			Modified:     book.ParseDate(p.MetaData.Modified),
+			Description:  p.MetaData.Description,
+			Keywords:     p.MetaData.Keywords,
+			Subjects:     p.MetaData.Subjects,
+			Language:     p.MetaData.Language,
+			Publisher:    p.MetaData.Publisher,
+			Rights:       p.MetaData.Rights,
+			Identifier:   p.MetaData.Identifier,
+			ISBN:         p.MetaData.ISBN,
+			Series:       p.MetaData.Series,
+			SeriesIndex:  p.MetaData.SeriesIndex,
+			Cover:        p.MetaData.Cover,
+		},
+		Options:      p.Options,
+		Translations: p.Translations,
+		Log:          os.Stdout,
+		Strict:       strict,
+		Clean:        true,
+	}
+
+	if checkCommand {
+		checkTemplates(config)
+	}
+
+	if serveCommand {
+		serveBook(src, config, portFlag)
+	}
+
+	if watchFlag {
+		watchBook(src, config, nil)
+	}
+
+	err = src.read(config)
 	if err != nil {
 		fail(err)
 	}
 
-	fmt.Printf("Created book in '%s' directory", outputDirectoryFlag)
+	err = bookprint.New(config)
+	if err != nil {
+		fail(err)
+	}
+
+	fmt.Printf("Created book in '%s' directory", outputDirectory)
 }
 
 func getUsage() {
@@ -145,28 +356,110 @@ func getVersion() string {
 	return fmt.Sprintf("BookPrint (unknown) %s/%s", runtime.GOOS, runtime.GOARCH)
 }
 
-func getFile(name string) ([]byte, error) {
-	var file []byte
+// getFormats splits the comma-separated list of output formats, e.g. "html, epub".
+func getFormats(list string) []string {
+	var formats []string
 
-	if name != "" {
-		if !fs.ExistFile(name) {
-			return file, fmt.Errorf("file '%s' does not exist", name)
+	for _, format := range strings.Split(list, ",") {
+		format = strings.TrimSpace(format)
+
+		if format != "" && !slices.Contains(formats, format) {
+			formats = append(formats, format)
 		}
+	}
+
+	return formats
+}
 
-		file, err := os.ReadFile(name)
+// getProject reads the given project file, or else the project file of the working directory.
+// Without project file, an empty project is returned.
+func getProject(name string) (*project.Project, error) {
+	if name == "" {
+		found, err := project.Find(".")
 		if err != nil {
-			return file, err
+			return nil, err
+		}
+
+		if found == "" {
+			return &project.Project{}, nil
 		}
 
-		return file, nil
+		name = found
 	}
 
-	file, err := fs.StdinAll()
-	if err != nil {
-		return file, err
+	if !fs.ExistFile(name) {
+		return nil, fmt.Errorf("project file '%s' does not exist", name)
+	}
+
+	return project.New(name)
+}
+
+// getContributors converts the contributors of the project file to contributors of the book.
+// The role defaults to author.
+func getContributors(contributors []project.Contributor) []*book.Contributor {
+	var result []*book.Contributor
+
+	for _, contributor := range contributors {
+		role := contributor.Role
+		if role == "" {
+			role = book.AuthorRole
+		}
+
+		result = append(result, &book.Contributor{
+			Name:        contributor.Name,
+			Role:        role,
+			SortName:    contributor.SortName,
+			Affiliation: contributor.Affiliation,
+		})
 	}
 
-	return file, nil
+	return result
+}
+
+// getSetFlags returns the long names of all flags set on the command line.
+func getSetFlags() map[string]bool {
+	setFlags := make(map[string]bool)
+
+	flag.Visit(func(f *flag.Flag) {
+		if name, ok := flagNames[f.Name]; ok {
+			setFlags[name] = true
+		} else {
+			setFlags[f.Name] = true
+		}
+	})
+
+	return setFlags
+}
+
+// getSetting returns the value of the flag, if it is set on the command line or the project
+// file has no value, else the value of the project file. Overridden values are reported.
+func getSetting(p *project.Project, setFlags map[string]bool, name string, flagValue string, projectValue string) string {
+	if projectValue == "" {
+		return flagValue
+	}
+
+	if !setFlags[name] {
+		return projectValue
+	}
+
+	if flagValue != projectValue {
+		printNote("flag '--%s %s' overrides value '%s' of project file '%s'", name, flagValue, projectValue, p.Name)
+	}
+
+	return flagValue
+}
+
+// formatInt returns the number as string, or an empty string for 0, i.e. an unset value.
+func formatInt(number int) string {
+	if number == 0 {
+		return ""
+	}
+
+	return strconv.Itoa(number)
+}
+
+func printNote(format string, arguments ...any) {
+	fmt.Printf("Note: %s\n", fmt.Sprintf(format, arguments...))
 }
 
 func fail(err error) {
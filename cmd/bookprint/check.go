@@ -0,0 +1,34 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"stefanco.de/bookprint/internal/bookprint"
+)
+
+// checkTemplates checks the templates against a sample book and prints the problems found.
+// It exits with status 1, if there are problems.
+func checkTemplates(config *bookprint.Config) {
+	checked, problems, err := bookprint.CheckTemplates(config)
+	if err != nil {
+		fail(err)
+	}
+
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+
+	if len(problems) > 0 {
+		fail(fmt.Errorf("found %d problem(s) in %d templates", len(problems), checked))
+	}
+
+	fmt.Printf("Checked %d templates without problems", checked)
+	os.Exit(0)
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package main
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"stefanco.de/bookprint/internal/theme"
+	"stefanco.de/bookprint/internal/util/fs"
+)
+
+// scaffold contains the sample source document and project file written by "bookprint init".
+//
+//go:embed scaffold
+var scaffold embed.FS
+
+// initProject writes the default theme, a sample source document and a project file into the
+// given directory for customization. Existing files are not overwritten.
+func initProject(directory string) error {
+	files, err := theme.Files()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range []string{"book.md", "bookprint.toml"} {
+		content, err := scaffold.ReadFile(path.Join("scaffold", name))
+		if err != nil {
+			return err
+		}
+
+		files[name] = content
+	}
+
+	var names []string
+	for name := range files {
+		if fs.ExistFile(filepath.Join(directory, filepath.FromSlash(name))) {
+			return fmt.Errorf("file '%s' already exists", filepath.Join(directory, filepath.FromSlash(name)))
+		}
+
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		fileName := filepath.Join(directory, filepath.FromSlash(name))
+
+		err := fs.MakeDir(filepath.Dir(fileName))
+		if err != nil {
+			return err
+		}
+
+		err = os.WriteFile(fileName, files[name], 0666)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"stefanco.de/bookprint/internal/bookprint"
+	"stefanco.de/bookprint/internal/serve"
+	"stefanco.de/bookprint/internal/util/fs"
+)
+
+// serveBook serves the book built into the temporary output directory on localhost, and
+// reloads its pages in the browser after every rebuild. The temporary output directory
+// is removed on interrupt.
+func serveBook(src *sources, config *bookprint.Config, port int) {
+	listener, err := net.Listen("tcp", net.JoinHostPort("localhost", strconv.Itoa(port)))
+	if err != nil {
+		_ = fs.RemoveDir(config.OutputDir)
+		fail(err)
+	}
+
+	server := serve.New(config.OutputDir)
+
+	go func() {
+		err := http.Serve(listener, server)
+		_ = fs.RemoveDir(config.OutputDir)
+		fail(err)
+	}()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-interrupt
+		_ = fs.RemoveDir(config.OutputDir)
+		os.Exit(0)
+	}()
+
+	// A multilingual book is served with its first language.
+	root := "/"
+	if codes := src.codes(); len(codes) > 0 {
+		root = "/" + codes[0] + "/"
+	}
+
+	fmt.Printf("Serving book at http://localhost:%d%s\n", listener.Addr().(*net.TCPAddr).Port, root)
+
+	watchBook(src, config, server.Reload)
+}
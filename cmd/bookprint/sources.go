@@ -0,0 +1,77 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"stefanco.de/bookprint/internal/bookprint"
+	"stefanco.de/bookprint/internal/source"
+)
+
+// sources are the input files of the book, or of every language of a multilingual book.
+type sources struct {
+	inputs    []string            // input files, or STDIN if empty
+	languages map[string][]string // input files of a multilingual book, key: language
+	format    string              // input format, detected by file extension if empty
+}
+
+// read reads the input files into the configuration, the languages of a multilingual
+// book in the order of their codes.
+func (s *sources) read(config *bookprint.Config) error {
+	if len(s.languages) == 0 {
+		file, err := source.New(s.inputs, s.format)
+		if err != nil {
+			return err
+		}
+
+		config.File = file
+		config.Languages = nil
+
+		return nil
+	}
+
+	var languages []*bookprint.Language
+
+	for _, code := range s.codes() {
+		file, err := source.New(s.languages[code], s.format)
+		if err != nil {
+			return fmt.Errorf("language '%s': %w", code, err)
+		}
+
+		languages = append(languages, &bookprint.Language{Code: code, File: file})
+	}
+
+	config.File = nil
+	config.Languages = languages
+
+	return nil
+}
+
+// paths returns the input files of all languages, e.g. for watching them.
+func (s *sources) paths() []string {
+	paths := append([]string{}, s.inputs...)
+
+	for _, code := range s.codes() {
+		paths = append(paths, s.languages[code]...)
+	}
+
+	return paths
+}
+
+// codes returns the sorted codes of the languages.
+func (s *sources) codes() []string {
+	var codes []string
+	for code := range s.languages {
+		codes = append(codes, code)
+	}
+
+	sort.Strings(codes)
+
+	return codes
+}
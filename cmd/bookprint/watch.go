@@ -0,0 +1,179 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"stefanco.de/bookprint/internal/bookprint"
+	"stefanco.de/bookprint/internal/util/fs"
+	"stefanco.de/bookprint/internal/watch"
+)
+
+const (
+	watchInterval = 300 * time.Millisecond // delay between two checks for changes
+	watchDebounce = 300 * time.Millisecond // delay without changes before a rebuild
+)
+
+// watchBook builds the book and rebuilds it whenever the input files, templates or static
+// files change. Errors are printed without exiting. Unchanged outputs are not rewritten,
+// changed static files are copied, and outputs of removed pages are deleted. The optional
+// function built is called after every successful rebuild.
+func watchBook(src *sources, config *bookprint.Config, built func()) {
+	paths := src.paths()
+	if config.TemplateDir != "" {
+		paths = append(paths, config.TemplateDir)
+	}
+	if config.StaticDir != "" {
+		paths = append(paths, config.StaticDir)
+	}
+
+	watcher := watch.New(paths, []string{config.OutputDir}, watchInterval, watchDebounce)
+
+	outputs, err := rebuild(src, config, nil)
+	if err != nil {
+		printError(err)
+	}
+
+	fmt.Println("Watching for changes, press Ctrl+C to stop")
+
+	for {
+		changes := watcher.Wait()
+
+		fmt.Printf("Changed '%s'\n", strings.Join(changes, "', '"))
+
+		for _, change := range changes {
+			err := updateStaticFile(change, config)
+			if err != nil {
+				printError(err)
+			}
+		}
+
+		rebuiltOutputs, err := rebuild(src, config, outputs)
+		if err != nil {
+			printError(err)
+			continue
+		}
+
+		outputs = rebuiltOutputs
+
+		if built != nil {
+			built()
+		}
+	}
+}
+
+// rebuild reads the input files and renders the book. Outputs of the previous build, which
+// are not part of the new build, are removed. It returns the outputs of the new build.
+func rebuild(src *sources, config *bookprint.Config, previousOutputs map[string]bool) (map[string]bool, error) {
+	err := src.read(config)
+	if err != nil {
+		return previousOutputs, err
+	}
+
+	config.Outputs = make(map[string]bool)
+
+	err = bookprint.New(config)
+	if err != nil {
+		return previousOutputs, err
+	}
+
+	for output := range previousOutputs {
+		if !config.Outputs[output] {
+			err := removeOutput(output, config)
+			if err != nil {
+				printError(err)
+			}
+		}
+	}
+
+	// Later builds only update the output directory.
+	config.Clean = false
+
+	fmt.Printf("Created book in '%s' directory\n", config.OutputDir)
+
+	return config.Outputs, nil
+}
+
+// updateStaticFile copies a changed file of the static directory into the output directory,
+// or into the directory of every language of a multilingual book, or removes it from there,
+// if it was removed. Other files are ignored.
+func updateStaticFile(name string, config *bookprint.Config) error {
+	if config.StaticDir == "" {
+		return nil
+	}
+
+	relativeName, err := filepath.Rel(config.StaticDir, name)
+	if err != nil || strings.HasPrefix(relativeName, "..") {
+		return nil
+	}
+
+	for _, directory := range config.LanguageDirs() {
+		outputFileName := filepath.Join(config.OutputDir, directory, relativeName)
+
+		if !fs.ExistFile(name) {
+			err := os.Remove(outputFileName)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+
+			continue
+		}
+
+		err = fs.MakeDir(filepath.Dir(outputFileName))
+		if err != nil {
+			return err
+		}
+
+		err = fs.CopyFile(name, outputFileName)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeOutput removes an output file, which is no longer created, and its directories,
+// if they are empty. Files copied from the static directory are kept.
+func removeOutput(output string, config *bookprint.Config) error {
+	name := filepath.FromSlash(output)
+
+	// Outputs of a multilingual book are within the directory of their language, e.g. "de/style.css".
+	staticName := name
+	for _, directory := range config.LanguageDirs() {
+		if relativeName, err := filepath.Rel(directory, name); err == nil && !strings.HasPrefix(relativeName, "..") {
+			staticName = relativeName
+			break
+		}
+	}
+
+	if config.StaticDir != "" && fs.ExistFile(filepath.Join(config.StaticDir, staticName)) {
+		return nil
+	}
+
+	err := os.Remove(filepath.Join(config.OutputDir, name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for directory := filepath.Dir(name); directory != "."; directory = filepath.Dir(directory) {
+		if os.Remove(filepath.Join(config.OutputDir, directory)) != nil {
+			break // not empty
+		}
+	}
+
+	return nil
+}
+
+func printError(err error) {
+	fmt.Printf("Error: %s\n", err)
+}
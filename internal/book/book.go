@@ -8,6 +8,7 @@ package book
 
 import (
 	"errors"
+	"fmt"
 	"html/template"
 
 	"golang.org/x/net/html"
@@ -16,18 +17,58 @@ import (
 )
 
 type Book struct {
-	MetaData *MetaData
-	Pages    []*Page
+	MetaData     *MetaData
+	Pages        []*Page
+	Translations []*Translation // the book in the other languages of a multilingual book, see LinkTranslations
+	Diagnostics  []*Diagnostic  `json:"-"` // problems of the source document, e.g. broken cross-references
 }
 
+// MetaData is the meta data of the "head" of the source document, see getMetaData.
 type MetaData struct {
-	Title   string
-	Author  string
-	Date    string
-	Preface template.HTML
+	Title        string
+	Author       string         // names of the authors, see SetContributors
+	Contributors []*Contributor // authors, editors, translators and illustrators in document order
+	Date         *Date          // nil, if not set
+	Published    *Date
+	Modified     *Date
+	Description  string
+	Keywords     []string
+	Subjects     []string
+	Language     string // e.g. "de", from the "lang" attribute of the "html" element
+	Publisher    string
+	Rights       string
+	Identifier   string
+	ISBN         string // without prefix, e.g. "978-3-16-148410-0" for "urn:isbn:978-3-16-148410-0"
+	Series       string
+	SeriesIndex  string            // position of the book in the series, e.g. "2"
+	Cover        string            // path or URL of the cover image
+	Meta         map[string]string // values of all "meta" elements by name, e.g. "generator"
+	Preface      template.HTML
 }
 
-func New(file []byte) (*Book, error) {
+// DefaultSplitLevel is the default heading level up to which a heading starts a new page,
+// i.e. every heading starts a new page.
+const DefaultSplitLevel = 6
+
+// Options configure how a book is created from an HTML document.
+type Options struct {
+	SplitLevel int        // headings up to this level start a new page, e.g. 2 for h1 and h2
+	Numbering  *Numbering // prefixes of the headings, e.g. "1.2"
+
+	// PathStrategy determines the file names of the pages: "numeric", "slug", "id" or a
+	// pattern like "{{.Prefix}}-{{.Slug}}.html", see getPath; defaults to "numeric".
+	PathStrategy string
+
+	// Layout of the pages in the output directory: "flat" or "nested"; defaults to "flat".
+	Layout string
+}
+
+func New(file []byte, options *Options) (*Book, error) {
+	options, err := getOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
 	tree, err := parsetree.New(string(file))
 	if err != nil {
 		return nil, err
@@ -49,94 +90,100 @@ func New(file []byte) (*Book, error) {
 		return nil, err
 	}
 
-	author, err := getAuthor(head)
+	metaData := getMetaData(head)
+	metaData.Title = title
+
+	metaData.Preface, err = getPreface(body, options.SplitLevel)
 	if err != nil {
 		return nil, err
 	}
 
-	date, err := getDate(head)
+	pages, err := Pages(body, options)
 	if err != nil {
 		return nil, err
 	}
 
-	preface, err := getPreface(body)
-	if err != nil {
-		return nil, err
+	var preface *Page
+	if metaData.Preface != "" {
+		preface = &Page{Path: indexFileName, Title: &Title{Text: metaData.Title}, Content: &Content{Html: metaData.Preface}}
 	}
 
-	pages, err := Pages(body)
+	diagnostics, err := ResolveCrossReferences(pages, preface)
 	if err != nil {
 		return nil, err
 	}
 
-	err = ResolveCrossReferences(pages)
-	if err != nil {
-		return nil, err
+	if preface != nil {
+		metaData.Preface = preface.Content.Html
 	}
 
 	book := &Book{
-		MetaData: &MetaData{
-			Title:   title,
-			Author:  author,
-			Date:    date,
-			Preface: preface,
-		},
-		Pages: pages,
+		MetaData:    metaData,
+		Pages:       pages,
+		Diagnostics: diagnostics,
 	}
 
 	return book, nil
 }
 
-func getTitle(head *html.Node) (string, error) {
-	for child := head.FirstChild; child != nil; child = child.NextSibling {
-		tag := child.Data
+// getOptions returns a copy of the options with default values for unset options.
+func getOptions(options *Options) (*Options, error) {
+	result := &Options{
+		SplitLevel: DefaultSplitLevel,
+		Numbering:  &Numbering{},
+	}
 
-		if tag == "title" {
-			return parsetree.Text(child), nil
-		}
+	if options != nil && options.SplitLevel != 0 {
+		result.SplitLevel = options.SplitLevel
 	}
 
-	return "", errors.New("passed HTML 'head' node contains no 'title'")
-}
+	if options != nil && options.Numbering != nil {
+		result.Numbering = options.Numbering
+	}
 
-func getAuthor(head *html.Node) (string, error) {
-	metas := parsetree.ElementsByTagName(head, "meta")
+	if options != nil {
+		result.PathStrategy = options.PathStrategy
+		result.Layout = options.Layout
+	}
 
-	for _, meta := range metas {
-		attributes := parsetree.AttributeMap(meta)
+	switch result.Layout {
+	case "":
+		result.Layout = FlatLayout
+	case FlatLayout, NestedLayout:
+	default:
+		return nil, fmt.Errorf("unknown layout '%s', available layouts are: %s, %s", result.Layout, FlatLayout, NestedLayout)
+	}
 
-		if name, hasNameAttribute := attributes["name"]; hasNameAttribute {
-			if name == "author" {
-				if content, hasContentAttribute := attributes["content"]; hasContentAttribute {
-					return content, nil
-				}
-			}
-		}
+	if result.SplitLevel < 1 || result.SplitLevel > 6 {
+		return nil, fmt.Errorf("split level must be between 1 and 6, got %d", result.SplitLevel)
 	}
 
-	return "", nil
-}
+	err := result.Numbering.Check()
+	if err != nil {
+		return nil, err
+	}
 
-func getDate(head *html.Node) (string, error) {
-	metas := parsetree.ElementsByTagName(head, "meta")
+	return result, nil
+}
 
-	for _, meta := range metas {
-		attributes := parsetree.AttributeMap(meta)
+func getTitle(head *html.Node) (string, error) {
+	for child := head.FirstChild; child != nil; child = child.NextSibling {
+		tag := child.Data
 
-		if name, hasNameAttribute := attributes["name"]; hasNameAttribute {
-			if name == "dcterms.date" {
-				if content, hasContentAttribute := attributes["content"]; hasContentAttribute {
-					return content, nil
-				}
-			}
+		if tag == "title" {
+			return parsetree.Text(child), nil
 		}
 	}
 
-	return "", nil
+	return "", errors.New("passed HTML 'head' node contains no 'title'")
 }
 
-func getPreface(body *html.Node) (template.HTML, error) {
-	preface, err := parsetree.Html(parsetree.SiblingsUntilFunc(body.FirstChild, parsetree.IsHeading)...)
+func getPreface(body *html.Node, splitLevel int) (template.HTML, error) {
+	if body.FirstChild == nil || isChapterHeading(splitLevel)(body.FirstChild) {
+		return "", nil
+	}
+
+	preface, err := parsetree.Html(append([]*html.Node{body.FirstChild}, parsetree.SiblingsUntilFunc(body.FirstChild, isChapterHeading(splitLevel))...)...)
 	if err != nil {
 		return preface, err
 	}
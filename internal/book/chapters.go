@@ -10,19 +10,32 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
+	"path"
 	"strings"
+	textTemplate "text/template"
+	"unicode"
 
 	"golang.org/x/net/html"
 
 	"stefanco.de/bookprint/internal/util/parsetree"
+	"stefanco.de/bookprint/internal/util/slug"
 )
 
 type Chapter struct {
-	Id      int
-	Level   int
-	Path    string
-	Title   *Title
-	Content *Content
+	Id       int
+	Level    int
+	Path     string
+	Anchor   string // id attribute of the heading
+	Title    *Title
+	Content  *Content
+	Sections []*Section
+}
+
+// Section is a heading below the split level, which stays in the content of its chapter.
+type Section struct {
+	Id    string // id attribute of the heading, used as anchor
+	Level int
+	Title *Title
 }
 
 type Title struct {
@@ -35,7 +48,10 @@ type Content struct {
 	Html template.HTML
 }
 
-func Chapters(body *html.Node) ([]*Chapter, error) {
+// Chapters returns a chapter for every heading up to the split level of the options, e.g. for h1 and h2
+// with split level 2. Deeper headings stay in the content of their chapter and become
+// sections, which get an id attribute, if they have none.
+func Chapters(body *html.Node, options *Options) ([]*Chapter, error) {
 	var chapters []*Chapter
 
 	if !parsetree.IsBody(body) {
@@ -44,12 +60,18 @@ func Chapters(body *html.Node) ([]*Chapter, error) {
 
 	headings := parsetree.Headings(body)
 
-	prefix := getPrefix()
+	splitLevel := options.SplitLevel
 
-	for index, heading := range headings {
-		id := index + 1
-		path := fmt.Sprintf("page%d.html", id)
+	setSectionIds(headings, splitLevel)
 
+	prefix := getPrefix(options.Numbering)
+
+	path, err := getPath(options.PathStrategy)
+	if err != nil {
+		return chapters, err
+	}
+
+	for _, heading := range headings {
 		level, err := parsetree.HeadingLevel(heading)
 		if err != nil {
 			return chapters, err
@@ -62,21 +84,45 @@ func Chapters(body *html.Node) ([]*Chapter, error) {
 			return chapters, err
 		}
 
-		content := parsetree.SiblingsUntilFunc(heading, parsetree.IsHeading)
+		title := &Title{
+			Prefix: prefix(heading), // heading prefix: 1, 1.1, 1.1.1, etc.
+			Html:   headingHtml,
+			Text:   headingText,
+		}
+
+		if level > splitLevel {
+			// Sections before the first chapter are part of the preface.
+			if len(chapters) > 0 {
+				chapter := chapters[len(chapters)-1]
+				chapter.Sections = append(chapter.Sections, &Section{
+					Id:    parsetree.AttributeMap(heading)["id"],
+					Level: level,
+					Title: title,
+				})
+			}
+
+			continue
+		}
+
+		id := len(chapters) + 1
+
+		chapterPath, err := path(heading, id, title)
+		if err != nil {
+			return chapters, err
+		}
+
+		content := parsetree.SiblingsUntilFunc(heading, isChapterHeading(splitLevel))
 		contentHtml, err := parsetree.Html(content...)
 		if err != nil {
 			return chapters, err
 		}
 
 		chapter := &Chapter{
-			Id:    id,
-			Level: level,
-			Path:  path,
-			Title: &Title{
-				Prefix: prefix(heading), // heading prefix: 1, 1.1, 1.1.1, etc.
-				Html:   headingHtml,
-				Text:   headingText,
-			},
+			Id:     id,
+			Level:  level,
+			Path:   chapterPath,
+			Anchor: parsetree.AttributeMap(heading)["id"],
+			Title:  title,
 			Content: &Content{
 				Html: contentHtml,
 			},
@@ -88,38 +134,159 @@ func Chapters(body *html.Node) ([]*Chapter, error) {
 	return chapters, nil
 }
 
-func getPrefix() func(*html.Node) string {
-	var h1Counter int
-	var h2Counter int
-	var h3Counter int
+// isChapterHeading returns a predicate matching all headings up to the split level.
+func isChapterHeading(splitLevel int) func(*html.Node) bool {
+	return func(node *html.Node) bool {
+		if !parsetree.IsHeading(node) {
+			return false
+		}
 
-	return func(heading *html.Node) string {
-		tagName := parsetree.TagName(heading)
+		level, err := parsetree.HeadingLevel(node)
 
-		if tagName == "h1" {
-			h1Counter++
-			h2Counter = 0
+		return err == nil && level <= splitLevel
+	}
+}
+
+// setSectionIds adds a unique id attribute to all headings below the split level without id,
+// so that they can be linked to.
+func setSectionIds(headings []*html.Node, splitLevel int) {
+	used := make(map[string]bool)
 
-			return fmt.Sprintf("%d", h1Counter)
+	for _, heading := range headings {
+		if id, ok := parsetree.AttributeMap(heading)["id"]; ok {
+			used[id] = true
 		}
+	}
 
-		if tagName == "h2" {
-			h2Counter++
-			h3Counter = 0
+	counter := 0
 
-			return fmt.Sprintf("%d.%d", h1Counter, h2Counter)
+	for _, heading := range headings {
+		if isChapterHeading(splitLevel)(heading) {
+			continue
 		}
 
-		if tagName == "h3" {
-			h3Counter++
+		if _, ok := parsetree.AttributeMap(heading)["id"]; ok {
+			continue
+		}
+
+		id := ""
+		for id == "" || used[id] {
+			counter++
+			id = fmt.Sprintf("section-%d", counter)
+		}
+
+		used[id] = true
+		heading.Attr = append(heading.Attr, html.Attribute{Key: "id", Val: id})
+	}
+}
+
+// Path strategies, which determine the file names of the pages.
+const (
+	NumericPaths = "numeric" // page1.html, page2.html, ...
+	SlugPaths    = "slug"    // the slug of the title, e.g. getting-started.html
+	IdPaths      = "id"      // the id of the heading, e.g. setup.html for <h1 id="setup">, or the slug
+)
+
+// reservedPaths are used by the "index.html" and "map.html" templates.
+var reservedPaths = []string{"index.html", "map.html"}
+
+// pathData is the data of a path pattern, e.g. "{{.Prefix}}-{{.Slug}}.html".
+type pathData struct {
+	Id     int    // number of the page, e.g. 2
+	Level  int    // level of the heading, e.g. 1
+	Prefix string // number prefix of the heading, e.g. "1.2"
+	Slug   string // slug of the title, e.g. "getting-started"
+	Anchor string // id attribute of the heading, e.g. "setup"
+}
+
+// getPath returns a function returning the path of the next page, according to the given
+// path strategy or path pattern. A "data-path" attribute of the heading always takes precedence.
+// Duplicate paths get a numeric suffix in document order, e.g. "setup-2.html". The function
+// returns an error, if the pattern can't be executed, e.g. for an unknown field like {{.Prefx}}.
+func getPath(strategy string) (func(*html.Node, int, *Title) (string, error), error) {
+	var pattern *textTemplate.Template
+
+	switch strategy {
+	case "", NumericPaths, SlugPaths, IdPaths:
+	default:
+		if !strings.Contains(strategy, "{{") {
+			return nil, fmt.Errorf("unknown path strategy '%s', available path strategies are: %s, %s, %s or a pattern like '{{.Prefix}}-{{.Slug}}.html'", strategy, NumericPaths, SlugPaths, IdPaths)
+		}
 
-			return fmt.Sprintf("%d.%d.%d", h1Counter, h2Counter, h3Counter)
+		var err error
+		pattern, err = textTemplate.New("path").Option("missingkey=error").Parse(strategy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path pattern '%s': %w", strategy, err)
 		}
+	}
 
-		return ""
+	used := make(map[string]bool)
+	for _, reservedPath := range reservedPaths {
+		used[reservedPath] = true
 	}
+
+	return func(heading *html.Node, id int, title *Title) (string, error) {
+		attributes := parsetree.AttributeMap(heading)
+		data := &pathData{
+			Id:     id,
+			Level:  parsetree.HeadingMap()[parsetree.TagName(heading)],
+			Prefix: title.Prefix,
+			Slug:   slug.Make(title.Text),
+			Anchor: attributes["id"],
+		}
+
+		var name string
+
+		switch {
+		case attributes["data-path"] != "":
+			name = attributes["data-path"]
+		case pattern != nil:
+			var stringBuilder strings.Builder
+
+			err := pattern.Execute(&stringBuilder, data)
+			if err != nil {
+				return "", fmt.Errorf("invalid path pattern '%s': %w", strategy, err)
+			}
+
+			name = stringBuilder.String()
+		case strategy == SlugPaths:
+			name = data.Slug
+		case strategy == IdPaths && data.Anchor != "":
+			name = data.Anchor
+		case strategy == IdPaths:
+			name = data.Slug
+		}
+
+		name = cleanPath(name)
+		if name == "" {
+			name = fmt.Sprintf("page%d", id)
+		}
+
+		if path.Ext(name) != ".html" {
+			name += ".html"
+		}
+
+		unique := name
+		for counter := 2; used[unique]; counter++ {
+			unique = fmt.Sprintf("%s-%d.html", strings.TrimSuffix(name, ".html"), counter)
+		}
+
+		used[unique] = true
+
+		return unique, nil
+	}, nil
 }
 
-func getPath() {
+// cleanPath returns a file name without characters, which are problematic in file names
+// or URLs, e.g. "/" or "#", and without leading or trailing hyphens and periods.
+func cleanPath(name string) string {
+	name = strings.Map(func(character rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|#%`, character) || unicode.IsSpace(character) || unicode.IsControl(character) {
+			return '-'
+		}
+
+		return character
+	}, name)
 
+	return strings.Trim(name, "-.")
 }
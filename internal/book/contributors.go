@@ -0,0 +1,97 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package book
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Roles of the contributors.
+const (
+	AuthorRole      = "author"
+	EditorRole      = "editor"
+	TranslatorRole  = "translator"
+	IllustratorRole = "illustrator"
+)
+
+// Roles are the supported roles of the contributors.
+var Roles = []string{AuthorRole, EditorRole, TranslatorRole, IllustratorRole}
+
+// roleCodes are the MARC relator codes of the roles, as used by EPUB.
+// See: https://www.loc.gov/marc/relators/relaterm.html
+var roleCodes = map[string]string{
+	AuthorRole:      "aut",
+	EditorRole:      "edt",
+	TranslatorRole:  "trl",
+	IllustratorRole: "ill",
+}
+
+// Contributor is a person, who contributed to the book in a role.
+type Contributor struct {
+	Name        string
+	Role        string // see Roles
+	SortName    string // name used for sorting, e.g. "Doe, Jane"
+	Affiliation string // e.g. "University of Vienna"
+}
+
+// Check returns an error, if the contributor has no name or an unknown role.
+func (c *Contributor) Check() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return fmt.Errorf("contributor with role '%s' has no name", c.Role)
+	}
+
+	if _, ok := roleCodes[c.Role]; !ok {
+		return fmt.Errorf("unknown role '%s' of contributor '%s', available roles are: %s", c.Role, c.Name, strings.Join(Roles, ", "))
+	}
+
+	return nil
+}
+
+// RoleCode returns the MARC relator code of the role, e.g. "trl" for a translator.
+func (c *Contributor) RoleCode() string {
+	return roleCodes[c.Role]
+}
+
+// ContributorsWithRole returns the contributors with the given role in document order,
+// e.g. {{range .MetaData.ContributorsWithRole "editor"}}.
+func (m *MetaData) ContributorsWithRole(role string) []*Contributor {
+	var contributors []*Contributor
+
+	for _, contributor := range m.Contributors {
+		if contributor.Role == role {
+			contributors = append(contributors, contributor)
+		}
+	}
+
+	return contributors
+}
+
+// SetContributors replaces the contributors and sets the author to the names of the authors.
+func (m *MetaData) SetContributors(contributors []*Contributor) {
+	m.Contributors = contributors
+
+	var names []string
+	for _, author := range m.ContributorsWithRole(AuthorRole) {
+		names = append(names, author.Name)
+	}
+
+	m.Author = strings.Join(names, ", ")
+}
+
+// getContributorRole returns the role of a normalized meta name, e.g. "translator",
+// or an empty string, if the meta element names no contributor.
+func getContributorRole(name string) string {
+	switch name {
+	case "author", "creator":
+		return AuthorRole
+	case "editor", "translator", "illustrator":
+		return name
+	}
+
+	return ""
+}
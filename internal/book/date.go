@@ -0,0 +1,195 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package book
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"stefanco.de/bookprint/internal/i18n"
+)
+
+// dateLayout is a supported format of dates with its ISO 8601 format of the same precision.
+type dateLayout struct {
+	layout string
+	iso    string
+}
+
+// dateLayouts are the supported formats of dates, e.g. of "dcterms.date". Month names may also
+// be German, e.g. "1. Juni 2023".
+var dateLayouts = []dateLayout{
+	{"2006-01-02", "2006-01-02"},
+	{time.RFC3339, time.RFC3339},
+	{"2006-01-02T15:04:05", "2006-01-02T15:04:05"},
+	{"2006-01-02 15:04:05", "2006-01-02T15:04:05"},
+	{"2006-01-02 15:04", "2006-01-02T15:04"},
+	{"2006-01", "2006-01"},
+	{"2006", "2006"},
+	{"02.01.2006", "2006-01-02"},
+	{"2.1.2006", "2006-01-02"},
+	{"January 2, 2006", "2006-01-02"},
+	{"Jan 2, 2006", "2006-01-02"},
+	{"2 January 2006", "2006-01-02"},
+	{"2. January 2006", "2006-01-02"},
+	{"January 2006", "2006-01"},
+}
+
+// monthNames are the month names of the supported languages besides English, from January to December.
+var monthNames = map[string][]string{
+	"de": {"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+}
+
+// weekdayNames are the weekday names of the supported languages besides English, from Sunday to Saturday.
+var weekdayNames = map[string][]string{
+	"de": {"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+}
+
+// englishNameRegexp matches the English month and weekday names, and their abbreviations,
+// as formatted by Go.
+var englishNameRegexp = regexp.MustCompile(`\b(January|February|March|April|May|June|July|August|September|October|November|December|` +
+	`Sunday|Monday|Tuesday|Wednesday|Thursday|Friday|Saturday|` +
+	`Jan|Feb|Mar|Apr|Jun|Jul|Aug|Sep|Oct|Nov|Dec|Sun|Mon|Tue|Wed|Thu|Fri|Sat)\b`)
+
+// foreignMonthRegexp matches the month names of monthNames, see toEnglishMonths.
+var foreignMonthRegexp = getForeignMonthRegexp()
+
+// Date is a date of the meta data with its text as written in the source document.
+type Date struct {
+	Text   string
+	Time   time.Time // zero, if the text has no supported format
+	layout *dateLayout
+}
+
+// ParseDate returns the date of a text, or nil for an empty text. A text with an unsupported
+// format results in a date without time.
+func ParseDate(text string) *Date {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+
+	english := toEnglishMonths(text)
+
+	for index := range dateLayouts {
+		parsed, err := time.Parse(dateLayouts[index].layout, english)
+		if err == nil {
+			return &Date{Text: text, Time: parsed, layout: &dateLayouts[index]}
+		}
+	}
+
+	return &Date{Text: text}
+}
+
+// DateFromTime returns the date of a time, with the text in ISO 8601 format.
+func DateFromTime(t time.Time) *Date {
+	layout := &dateLayout{time.RFC3339, time.RFC3339}
+
+	return &Date{Text: t.Format(layout.iso), Time: t, layout: layout}
+}
+
+// String returns the text of the date, so that {{.MetaData.Date}} prints it as written.
+func (d *Date) String() string {
+	return d.Text
+}
+
+// IsValid returns true, if the text of the date has a supported format.
+func (d *Date) IsValid() bool {
+	return d.layout != nil
+}
+
+// ISO returns the date in ISO 8601 format with the precision of its text, e.g. "2023-06-01"
+// for "1. Juni 2023" or "2023-06" for "June 2023". A date with an unsupported format
+// returns its text.
+func (d *Date) ISO() string {
+	if d.layout == nil {
+		return d.Text
+	}
+
+	return d.Time.Format(d.layout.iso)
+}
+
+// Format formats the date with a Go time layout in the given language, e.g. "1. Juni 2023" for
+// the layout "2. January 2006" and the language "de". Languages without own month names are
+// formatted in English. The date must have a supported format, see IsValid.
+func (d *Date) Format(layout string, language string) string {
+	return FormatTime(d.Time, layout, language)
+}
+
+// FormatTime formats a time with a Go time layout in the given language, see Date.Format.
+func FormatTime(t time.Time, layout string, language string) string {
+	formatted := t.Format(layout)
+
+	language = i18n.BaseLanguage(language)
+	months, weekdays := monthNames[language], weekdayNames[language]
+	if months == nil {
+		return formatted
+	}
+
+	return englishNameRegexp.ReplaceAllStringFunc(formatted, func(name string) string {
+		for index := range months {
+			month := time.Month(index + 1).String()
+			if name == month {
+				return months[index]
+			}
+			if name == month[:3] {
+				return getAbbreviation(months[index])
+			}
+		}
+
+		for index := range weekdays {
+			weekday := time.Weekday(index).String()
+			if name == weekday {
+				return weekdays[index]
+			}
+			if name == weekday[:3] {
+				return getAbbreviation(weekdays[index])
+			}
+		}
+
+		return name
+	})
+}
+
+// toEnglishMonths replaces the month names of all supported languages in a text with the
+// English month names, so that the text can be parsed, e.g. "1. June 2023" for "1. Juni 2023".
+func toEnglishMonths(text string) string {
+	return foreignMonthRegexp.ReplaceAllStringFunc(text, func(name string) string {
+		for _, months := range monthNames {
+			for index, month := range months {
+				if strings.EqualFold(name, month) {
+					return time.Month(index + 1).String()
+				}
+			}
+		}
+
+		return name
+	})
+}
+
+// getForeignMonthRegexp returns a case-insensitive regular expression matching the month
+// names of all supported languages besides English.
+func getForeignMonthRegexp() *regexp.Regexp {
+	var names []string
+	for _, months := range monthNames {
+		for _, month := range months {
+			names = append(names, regexp.QuoteMeta(month))
+		}
+	}
+
+	return regexp.MustCompile(`(?i)\b(` + strings.Join(names, "|") + `)\b`)
+}
+
+// getAbbreviation returns the first three letters of a name, e.g. "Mär" for "März".
+func getAbbreviation(name string) string {
+	letters := []rune(name)
+	if len(letters) <= 3 {
+		return name
+	}
+
+	return string(letters[:3])
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package book
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Kinds of diagnostics.
+const (
+	UnresolvedLink   = "unresolved link"
+	AmbiguousLink    = "ambiguous link"
+	DuplicateTitle   = "duplicate title"
+	DuplicateId      = "duplicate id"
+	UntranslatedPage = "untranslated page"
+)
+
+// Diagnostic is a problem of the source document, which does not prevent creating the book,
+// e.g. a cross-reference without target.
+type Diagnostic struct {
+	Kind    string
+	Path    string // path of the page containing the problem
+	Title   string // title of the page containing the problem
+	Href    string // link target, if the problem is a link
+	Text    string // link text, if the problem is a link
+	Message string
+}
+
+func (d *Diagnostic) String() string {
+	if d.Href == "" {
+		return fmt.Sprintf("%s on page '%s' (%s): %s", d.Kind, d.Title, d.Path, d.Message)
+	}
+
+	return fmt.Sprintf("%s '%s' with text '%s' on page '%s' (%s): %s", d.Kind, d.Href, d.Text, d.Title, d.Path, d.Message)
+}
+
+// heading is the heading of a page or of a section, a target of cross-references by title
+// and of fragment links.
+type heading struct {
+	page    *Page
+	section *Section // nil for the heading of the page
+}
+
+func (h *heading) String() string {
+	if h.section == nil {
+		return fmt.Sprintf("page '%s'", h.page.Path)
+	}
+
+	return fmt.Sprintf("section '%s#%s'", h.page.Path, h.section.Id)
+}
+
+// getDuplicates reports pages and sections with the same title or heading id as a previous page
+// or section. Cross-references by title refer to the first page with the title, or else to the
+// first section, and fragment links refer to the first heading with the id.
+func getDuplicates(pages []*Page) []*Diagnostic {
+	var diagnostics []*Diagnostic
+	var headings []*heading
+
+	for _, page := range pages {
+		headings = append(headings, &heading{page: page})
+
+		for _, section := range page.Sections {
+			headings = append(headings, &heading{page: page, section: section})
+		}
+	}
+
+	titles := make(map[string][]*heading) // key: title text
+	ids := make(map[string]*heading)      // key: id, value: first heading with the id
+
+	for _, current := range headings {
+		title := current.page.Title
+		id := current.page.Anchor
+		if current.section != nil {
+			title = current.section.Title
+			id = current.section.Id
+		}
+
+		titles[title.Text] = append(titles[title.Text], current)
+
+		if id == "" {
+			continue
+		}
+
+		first, exists := ids[id]
+		if !exists {
+			ids[id] = current
+			continue
+		}
+
+		diagnostics = append(diagnostics, &Diagnostic{
+			Kind:    DuplicateId,
+			Path:    current.page.Path,
+			Title:   current.page.Title.Text,
+			Message: fmt.Sprintf("%ssame id '%s' as %s, fragment links refer to %s", getSectionPrefix(current), id, first, first),
+		})
+	}
+
+	for _, current := range headings {
+		title := current.page.Title
+		if current.section != nil {
+			title = current.section.Title
+		}
+
+		duplicates := titles[title.Text]
+		if len(duplicates) < 2 || duplicates[0] == current {
+			continue
+		}
+
+		referenced := duplicates[0]
+		for _, duplicate := range duplicates {
+			if duplicate.section == nil {
+				referenced = duplicate // pages take precedence over sections
+				break
+			}
+		}
+
+		diagnostics = append(diagnostics, &Diagnostic{
+			Kind:    DuplicateTitle,
+			Path:    current.page.Path,
+			Title:   current.page.Title.Text,
+			Message: fmt.Sprintf("%ssame title as %s, cross-references by title refer to %s", getSectionPrefix(current), duplicates[0], referenced),
+		})
+	}
+
+	return diagnostics
+}
+
+// getSectionPrefix returns the start of the message of a duplicate section, e.g. "section
+// 'Install' has the ", or an empty string for a page.
+func getSectionPrefix(h *heading) string {
+	if h.section == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("section '%s' has the ", h.section.Title.Text)
+}
+
+// isInternalLink reports, whether the link is expected to be resolved as cross-reference,
+// i.e. it is a fragment link or a relative link without file extension, e.g. "Getting%20Started".
+// Links to other files, e.g. "images/figure.png", to directories, e.g. "docs/" or "..", and
+// absolute URLs are not checked.
+func isInternalLink(href string) bool {
+	if strings.HasPrefix(href, "#") {
+		return len(href) > 1
+	}
+
+	reference, err := url.Parse(href)
+	if err != nil {
+		return true // e.g. "100% Coverage" is a title, but no valid URL
+	}
+
+	if reference.Scheme != "" || reference.Host != "" || reference.Path == "" {
+		return false
+	}
+
+	unescaped, err := url.QueryUnescape(reference.Path)
+	if err != nil {
+		unescaped = reference.Path
+	}
+
+	if strings.HasSuffix(unescaped, "/") || path.Base(unescaped) == ".." || path.Base(unescaped) == "." {
+		return false
+	}
+
+	return path.Ext(unescaped) == "" || strings.Contains(unescaped, " ")
+}
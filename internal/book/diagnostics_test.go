@@ -0,0 +1,40 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package book
+
+import (
+	"testing"
+)
+
+func TestIsInternalLink(t *testing.T) {
+	tests := []struct {
+		href     string
+		expected bool
+	}{
+		{"#install", true},
+		{"#", false},
+		{"Getting%20Started", true},
+		{"Getting Started", true},
+		{"100% Coverage", true},
+		{"images/figure.png", false},
+		{"https://example.org/docs", false},
+		{"docs/", false},
+		{"../", false},
+		{"..", false},
+		{"../..", false},
+		{"./", false},
+		{"docs/#install", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.href, func(t *testing.T) {
+			if result := isInternalLink(test.href); result != test.expected {
+				t.Errorf("expected %t for '%s', got %t", test.expected, test.href, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package book
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"stefanco.de/bookprint/internal/util/parsetree"
+	"stefanco.de/bookprint/internal/util/slices"
+)
+
+// Layouts of the pages in the output directory.
+const (
+	FlatLayout   = "flat"   // all pages in the output directory, e.g. "1.2-setup.html"
+	NestedLayout = "nested" // a directory per page within the directory of its parent, e.g. "1-intro/1.2-setup/index.html"
+)
+
+// indexFileName is the file name of the pages of the nested layout.
+const indexFileName = "index.html"
+
+// urlAttributes are the attributes of the content, whose values are URLs, e.g. "src" of images.
+var urlAttributes = []string{"href", "src", "poster", "srcset"}
+
+// setNestedPaths moves every chapter into a directory named after its path, within the
+// directory of its closest parent chapter, e.g. "1.2-setup.html" becomes
+// "1-intro/1.2-setup/index.html".
+func setNestedPaths(chapters []*Chapter) {
+	for _, chapter := range chapters {
+		directory := strings.TrimSuffix(chapter.Path, path.Ext(chapter.Path))
+
+		var closest *Chapter
+		for _, parent := range getParents(chapter, chapters) {
+			if closest == nil || parent.Level > closest.Level {
+				closest = parent
+			}
+		}
+
+		if closest != nil {
+			directory = path.Join(path.Dir(closest.Path), directory)
+		}
+
+		chapter.Path = path.Join(directory, indexFileName)
+	}
+}
+
+// RelativeURL returns the URL of a page or asset relative to the page with the given path.
+// Both paths are relative to the output directory, e.g. "../1.3-usage/index.html#run" for
+// "1-intro/1.3-usage/index.html#run" from "1-intro/1.2-setup/index.html". Absolute URLs and
+// fragment links are returned unchanged.
+//
+// With pretty, links to "index.html" files become links to their directories,
+// e.g. "../1.3-usage/#run".
+func RelativeURL(from string, to string, pretty bool) string {
+	reference, err := url.Parse(to)
+	if err != nil || reference.Scheme != "" || reference.Host != "" || strings.HasPrefix(to, "/") || strings.HasPrefix(to, "#") {
+		return to
+	}
+
+	target, fragment, _ := strings.Cut(to, "#")
+	target, query, hasQuery := strings.Cut(target, "?")
+
+	if target == "" {
+		return to
+	}
+
+	fromSegments := strings.Split(path.Dir(path.Clean(from)), "/")
+	if fromSegments[0] == "." {
+		fromSegments = nil
+	}
+
+	toSegments := strings.Split(path.Clean(target), "/")
+
+	common := 0
+	for common < len(fromSegments) && common < len(toSegments)-1 && fromSegments[common] == toSegments[common] {
+		common++
+	}
+
+	segments := make([]string, 0, len(fromSegments)-common+len(toSegments)-common)
+	for range fromSegments[common:] {
+		segments = append(segments, "..")
+	}
+	segments = append(segments, toSegments[common:]...)
+
+	relative := strings.Join(segments, "/")
+	if strings.HasSuffix(target, "/") {
+		relative += "/" // e.g. "../setup/" for a link to a directory
+	}
+
+	if pretty && path.Base(relative) == indexFileName {
+		relative = strings.TrimSuffix(relative, indexFileName)
+		if relative == "" {
+			relative = "./"
+		}
+	}
+
+	if hasQuery {
+		relative += "?" + query
+	}
+
+	if fragment != "" {
+		relative += "#" + fragment
+	}
+
+	return relative
+}
+
+// RewriteURLs replaces the URLs of all elements of a node and its descendants with the results of
+// rewrite, e.g. to make them relative to another page. The URLs of a "srcset" attribute are
+// rewritten one by one, e.g. "a.png" and "b.png" of "a.png 1x, b.png 2x".
+func RewriteURLs(node *html.Node, rewrite func(element *html.Node, url string) string) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if parsetree.IsElement(child) {
+			for index, attribute := range child.Attr {
+				switch {
+				case attribute.Key == "srcset":
+					child.Attr[index].Val = rewriteSrcset(attribute.Val, func(url string) string {
+						return rewrite(child, url)
+					})
+				case slices.Contains(urlAttributes, attribute.Key) && attribute.Val != "":
+					child.Attr[index].Val = rewrite(child, attribute.Val)
+				}
+			}
+		}
+
+		RewriteURLs(child, rewrite)
+	}
+}
+
+// rewriteSrcset rewrites the URLs of a "srcset" attribute, keeping their descriptors.
+func rewriteSrcset(srcset string, rewrite func(url string) string) string {
+	var candidates []string
+
+	for _, candidate := range strings.Split(srcset, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+
+		fields[0] = rewrite(fields[0])
+		candidates = append(candidates, strings.Join(fields, " "))
+	}
+
+	return strings.Join(candidates, ", ")
+}
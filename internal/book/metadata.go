@@ -0,0 +1,183 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package book
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"stefanco.de/bookprint/internal/i18n"
+	"stefanco.de/bookprint/internal/util/parsetree"
+)
+
+// dublinCorePrefixes are the prefixes of Dublin Core meta names, e.g. "dcterms.date" or "DC.rights".
+// Names are compared case-insensitively and without these prefixes, so that "dcterms.publisher",
+// "DC.publisher" and "publisher" are the same.
+var dublinCorePrefixes = []string{"dcterms.", "dcterms:", "dc.", "dc:"}
+
+// isbnPrefixes are the prefixes of identifiers, which are an ISBN, e.g. "urn:isbn:978-3-16-148410-0".
+var isbnPrefixes = []string{"urn:isbn:", "isbn:", "isbn "}
+
+// getMetaData returns the meta data of the "meta" elements of the head and of the "lang" attribute
+// of the "html" element. The first value of a name wins, except for the contributors, keywords and
+// subjects, which collect all values. The title is not set.
+func getMetaData(head *html.Node) *MetaData {
+	metaData := &MetaData{}
+
+	if head.Parent != nil && parsetree.TagName(head.Parent) == "html" {
+		metaData.Language = strings.TrimSpace(parsetree.AttributeMap(head.Parent)["lang"])
+	}
+
+	for _, meta := range parsetree.ElementsByTagName(head, "meta") {
+		attributes := parsetree.AttributeMap(meta)
+
+		name, hasName := attributes["name"]
+		if !hasName {
+			name, hasName = attributes["property"] // e.g. Open Graph's "og:image"
+		}
+
+		content, hasContent := attributes["content"]
+		content = strings.TrimSpace(content)
+
+		if !hasName || !hasContent || name == "" || content == "" {
+			continue
+		}
+
+		metaData.addMeta(name, content)
+		metaData.setField(getMetaName(name), content)
+
+		if role := getContributorRole(getMetaName(name)); role != "" {
+			metaData.Contributors = append(metaData.Contributors, &Contributor{
+				Name:        content,
+				Role:        role,
+				SortName:    strings.TrimSpace(attributes["data-sort-name"]),
+				Affiliation: strings.TrimSpace(attributes["data-affiliation"]),
+			})
+		}
+	}
+
+	metaData.SetContributors(metaData.Contributors)
+
+	return metaData
+}
+
+// Direction returns the writing direction of the language of the book, i.e. "rtl" or "ltr",
+// e.g. for {{.MetaData.Direction}} in the "dir" attribute.
+func (m *MetaData) Direction() string {
+	return i18n.Direction(m.Language)
+}
+
+// addMeta adds the value of a "meta" element to the map of all meta elements. Values of
+// repeated names are joined with a comma.
+func (m *MetaData) addMeta(name string, content string) {
+	if m.Meta == nil {
+		m.Meta = make(map[string]string)
+	}
+
+	if existing, ok := m.Meta[name]; ok {
+		m.Meta[name] = existing + ", " + content
+		return
+	}
+
+	m.Meta[name] = content
+}
+
+// setField sets the typed field of the normalized meta name, unless it is already set.
+// Contributors are collected by getMetaData.
+func (m *MetaData) setField(name string, content string) {
+	setOnce := func(field *string) {
+		if *field == "" {
+			*field = content
+		}
+	}
+
+	setDateOnce := func(field **Date) {
+		if *field == nil {
+			*field = ParseDate(content)
+		}
+	}
+
+	switch name {
+	case "date", "created":
+		setDateOnce(&m.Date)
+	case "issued", "published", "article:published_time":
+		setDateOnce(&m.Published)
+	case "modified", "last-modified", "article:modified_time":
+		setDateOnce(&m.Modified)
+	case "description", "abstract":
+		setOnce(&m.Description)
+	case "keywords":
+		m.Keywords = append(m.Keywords, splitList(content)...)
+	case "subject":
+		m.Subjects = append(m.Subjects, content)
+	case "language":
+		setOnce(&m.Language)
+	case "publisher":
+		setOnce(&m.Publisher)
+	case "rights", "copyright", "license":
+		setOnce(&m.Rights)
+	case "identifier":
+		setOnce(&m.Identifier)
+
+		if isbn := getIsbn(content); isbn != "" && m.ISBN == "" {
+			m.ISBN = isbn
+		}
+	case "isbn":
+		if isbn := getIsbn(content); isbn != "" {
+			content = isbn
+		}
+
+		setOnce(&m.ISBN)
+	case "series", "calibre:series", "belongs-to-collection":
+		setOnce(&m.Series)
+	case "series-index", "calibre:series_index", "group-position":
+		setOnce(&m.SeriesIndex)
+	case "cover", "cover-image", "og:image":
+		setOnce(&m.Cover)
+	}
+}
+
+// getMetaName returns the lower-case name of a meta element without Dublin Core prefix.
+func getMetaName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	for _, prefix := range dublinCorePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return strings.TrimPrefix(name, prefix)
+		}
+	}
+
+	return name
+}
+
+// getIsbn returns the ISBN of an identifier like "urn:isbn:978-3-16-148410-0", or an
+// empty string, if the identifier is no ISBN.
+func getIsbn(identifier string) string {
+	lower := strings.ToLower(identifier)
+
+	for _, prefix := range isbnPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return strings.TrimSpace(identifier[len(prefix):])
+		}
+	}
+
+	return ""
+}
+
+// splitList splits a comma-separated list like "go, books" into its trimmed, non-empty items.
+func splitList(list string) []string {
+	var items []string
+
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
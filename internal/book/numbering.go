@@ -0,0 +1,179 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package book
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"stefanco.de/bookprint/internal/util/parsetree"
+	"stefanco.de/bookprint/internal/util/slices"
+)
+
+// Numbering schemes, named like the CSS list style types.
+const (
+	Decimal    = "decimal"     // 1, 2, 3, ...
+	LowerRoman = "lower-roman" // i, ii, iii, ...
+	UpperRoman = "upper-roman" // I, II, III, ...
+	LowerAlpha = "lower-alpha" // a, b, c, ...
+	UpperAlpha = "upper-alpha" // A, B, C, ...
+)
+
+var numberingSchemes = []string{Decimal, LowerRoman, UpperRoman, LowerAlpha, UpperAlpha}
+
+// Class names of headings, as used by Pandoc.
+const (
+	unnumberedClass = "unnumbered"
+	appendixClass   = "appendix"
+)
+
+// DefaultNumberingDepth is the deepest numbered heading level by default, i.e. h3.
+const DefaultNumberingDepth = 3
+
+// Numbering configures the prefixes of the headings, e.g. "1.2" or "A.1".
+//
+// Headings with the class "unnumbered" get no prefix and do not advance the counters.
+// The first heading with the class "appendix" starts the appendix: The numbering of the
+// top-level headings restarts with the appendix scheme, e.g. A, B, C.
+type Numbering struct {
+	Depth    int      // deepest numbered heading level, e.g. 6 for h1 to h6; defaults to 3
+	Schemes  []string // numbering scheme per heading level, starting with h1; defaults to decimal
+	Appendix string   // numbering scheme of the top-level headings of the appendix; defaults to upper-alpha
+}
+
+// Check returns an error if the depth or one of the numbering schemes is invalid.
+func (n *Numbering) Check() error {
+	if n.Depth < 0 || n.Depth > 6 {
+		return fmt.Errorf("numbering depth must be between 1 and 6, got %d", n.Depth)
+	}
+
+	schemes := append([]string{n.Appendix}, n.Schemes...)
+
+	for _, scheme := range schemes {
+		if scheme != "" && !slices.Contains(numberingSchemes, scheme) {
+			return fmt.Errorf("unknown numbering scheme '%s', available numbering schemes are: %s", scheme, strings.Join(numberingSchemes, ", "))
+		}
+	}
+
+	return nil
+}
+
+func (n *Numbering) depth() int {
+	if n.Depth == 0 {
+		return DefaultNumberingDepth
+	}
+
+	return n.Depth
+}
+
+func (n *Numbering) scheme(level int, isAppendix bool) string {
+	if level == 1 && isAppendix {
+		if n.Appendix == "" {
+			return UpperAlpha
+		}
+
+		return n.Appendix
+	}
+
+	if level <= len(n.Schemes) && n.Schemes[level-1] != "" {
+		return n.Schemes[level-1]
+	}
+
+	return Decimal
+}
+
+// getPrefix returns a function returning the prefix of the next heading, e.g. "1.2.1".
+func getPrefix(numbering *Numbering) func(*html.Node) string {
+	var counters [6]int
+	var isAppendix bool
+
+	return func(heading *html.Node) string {
+		level, err := parsetree.HeadingLevel(heading)
+		if err != nil {
+			return ""
+		}
+
+		classes := strings.Fields(parsetree.AttributeMap(heading)["class"])
+
+		if slices.Contains(classes, appendixClass) && !isAppendix {
+			isAppendix = true
+			counters = [6]int{}
+		}
+
+		if slices.Contains(classes, unnumberedClass) || level > numbering.depth() {
+			return ""
+		}
+
+		counters[level-1]++
+
+		// reset the counters of all deeper levels
+		for index := level; index < len(counters); index++ {
+			counters[index] = 0
+		}
+
+		var numbers []string
+		for index := 0; index < level; index++ {
+			numbers = append(numbers, formatNumber(counters[index], numbering.scheme(index+1, isAppendix)))
+		}
+
+		return strings.Join(numbers, ".")
+	}
+}
+
+// formatNumber formats a number with the given numbering scheme.
+// Zero, i.e. a skipped heading level, is always formatted as "0".
+func formatNumber(number int, scheme string) string {
+	if number <= 0 {
+		return "0"
+	}
+
+	switch scheme {
+	case LowerRoman:
+		return strings.ToLower(toRoman(number))
+	case UpperRoman:
+		return toRoman(number)
+	case LowerAlpha:
+		return strings.ToLower(toAlpha(number))
+	case UpperAlpha:
+		return toAlpha(number)
+	default:
+		return fmt.Sprintf("%d", number)
+	}
+}
+
+// toRoman returns the Roman numeral of a number, e.g. "XIV" for 14.
+func toRoman(number int) string {
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	symbols := []string{"M", "CM", "D", "CD", "C", "XC", "L", "XL", "X", "IX", "V", "IV", "I"}
+
+	var stringBuilder strings.Builder
+
+	for index, value := range values {
+		for number >= value {
+			stringBuilder.WriteString(symbols[index])
+			number -= value
+		}
+	}
+
+	return stringBuilder.String()
+}
+
+// toAlpha returns the letters of a number like spreadsheet columns, e.g. "A" for 1,
+// "Z" for 26 and "AA" for 27.
+func toAlpha(number int) string {
+	var letters []byte
+
+	for number > 0 {
+		number--
+		letters = append([]byte{byte('A' + number%26)}, letters...)
+		number /= 26
+	}
+
+	return string(letters)
+}
@@ -16,39 +16,50 @@ import (
 )
 
 type Page struct {
-	Id          int
-	Level       int
-	Path        string
-	Title       *Title
-	Content     *Content
-	Next        *Chapter
-	HasNext     bool
-	Previous    *Chapter
-	HasPrevious bool
-	Parents     []*Chapter
-	HasParents  bool
-	Children    []*Chapter
-	HasChildren bool
+	Id           int
+	Level        int
+	Path         string
+	Anchor       string // id attribute of the heading
+	Title        *Title
+	Content      *Content
+	Sections     []*Section
+	Next         *Chapter
+	HasNext      bool
+	Previous     *Chapter
+	HasPrevious  bool
+	Parents      []*Chapter
+	HasParents   bool
+	Children     []*Chapter
+	HasChildren  bool
+	Translations []*Translation // the page in the other languages of a multilingual book, see LinkTranslations
 }
 
-func Pages(body *html.Node) ([]*Page, error) {
+// Pages returns a page for every chapter, see Chapters. The navigation between the pages,
+// i.e. their parents, children, next and previous pages, only considers chapters.
+func Pages(body *html.Node, options *Options) ([]*Page, error) {
 	var pages []*Page
 
 	if !parsetree.IsBody(body) {
 		return pages, errors.New("passed HTML node is not a 'body' element")
 	}
 
-	chapters, err := Chapters(body)
+	chapters, err := Chapters(body, options)
 	if err != nil {
 		return pages, err
 	}
 
+	if options.Layout == NestedLayout {
+		setNestedPaths(chapters)
+	}
+
 	for _, chapter := range chapters {
 		id := chapter.Id
 		level := chapter.Level
 		path := chapter.Path
+		anchor := chapter.Anchor
 		title := chapter.Title
 		content := chapter.Content
+		sections := chapter.Sections
 
 		next := getNext(chapter, chapters)
 		hasNext := next != nil
@@ -59,15 +70,17 @@ func Pages(body *html.Node) ([]*Page, error) {
 		parents := getParents(chapter, chapters)
 		hasParents := !slices.IsEmpty(parents)
 
-		children := getChildren(chapter, chapters)
+		children := getChildren(chapter, chapters, options.SplitLevel)
 		hasChildren := !slices.IsEmpty(children)
 
 		page := &Page{
 			Id:          id,
 			Level:       level,
 			Path:        path,
+			Anchor:      anchor,
 			Title:       title,
 			Content:     content,
+			Sections:    sections,
 			Next:        next,
 			HasNext:     hasNext,
 			Previous:    previous,
@@ -149,7 +162,9 @@ func getPrevious(chapter *Chapter, chapters []*Chapter) *Chapter {
 	return nil
 }
 
-func getChildren(chapter *Chapter, chapters []*Chapter) []*Chapter {
+// getChildren returns the chapters one level below the chapter up to the next chapter of the same
+// or a lower level. Only headings up to the split level are considered, which start a page.
+func getChildren(chapter *Chapter, chapters []*Chapter, splitLevel int) []*Chapter {
 	var children []*Chapter
 
 	nextChapterIndex := slices.Index(chapters, chapter) + 1
@@ -158,7 +173,7 @@ func getChildren(chapter *Chapter, chapters []*Chapter) []*Chapter {
 		current := chapters[index]
 
 		isChild := current.Level == chapter.Level+1
-		isConsidered := current.Level <= 3 // only h1, h2, h3 are considered
+		isConsidered := current.Level <= splitLevel
 
 		if isChild && isConsidered {
 			children = append(children, current)
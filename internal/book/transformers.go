@@ -7,60 +7,292 @@
 package book
 
 import (
+	"fmt"
 	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
 
 	"stefanco.de/bookprint/internal/util/parsetree"
 	"stefanco.de/bookprint/internal/util/slices"
 )
 
+// target is the page on which an element with an id ended up.
+type target struct {
+	page     *Page
+	isAnchor bool // the id belongs to the heading of the page, i.e. the page itself
+	count    int  // number of elements with the id
+}
+
 // ResolveCrossReferences replaces cross-references to other pages with their corresponding paths.
-func ResolveCrossReferences(pages []*Page) error {
-	for _, page := range pages {
+//
+// Fragment links, e.g. "#setup", refer to the element with the id on any page and become links
+// to the page of the element, e.g. "page2.html#setup". Fragment links to the heading of a page
+// become links to the page itself, e.g. "page2.html". All paths are relative to the page
+// containing the link, see RelativeURL.
+//
+// All other links are matched against the titles of the pages and sections. Cross-references
+// to sections become links to the anchor of the section on its page, e.g. "page2.html#section-1",
+// or only the anchor if the section is on the same page.
+//
+// All other relative URLs of the content, e.g. of images, refer to the output directory and are
+// made relative to the page, e.g. "../img/figure.png" for "img/figure.png" on "1-intro/index.html".
+//
+// The preface, if not nil, is the content before the first page on "index.html". Its ids are
+// targets of fragment links, e.g. "index.html#license", and its links are resolved, too.
+//
+// Links which can't be resolved, links to ambiguous targets and duplicate titles are
+// returned as diagnostics.
+func ResolveCrossReferences(pages []*Page, preface *Page) ([]*Diagnostic, error) {
+	var bodies []*html.Node
+
+	diagnostics := getDuplicates(pages)
+
+	targets := make(map[string]*target) // key: element id
+
+	// The preface is no page, so that it is neither matched by title nor reported as duplicate.
+	linkedPages := pages
+	if preface != nil {
+		linkedPages = append([]*Page{preface}, pages...)
+	}
+
+	for _, page := range linkedPages {
 		tree, err := parsetree.New(string(page.Content.Html))
 		if err != nil {
-			return err
+			return diagnostics, err
 		}
 
 		body := parsetree.Body(tree)
+		bodies = append(bodies, body)
+
+		ids := getIds(body)
+		if page.Anchor != "" {
+			ids = append([]string{page.Anchor}, ids...)
+		}
+
+		for _, id := range ids {
+			if existing, exists := targets[id]; exists {
+				existing.count++
+				continue
+			}
+
+			targets[id] = &target{page: page, isAnchor: id == page.Anchor, count: 1}
+		}
+	}
+
+	for pageIndex, page := range linkedPages {
+		body := bodies[pageIndex]
 		links := parsetree.ElementsByTagName(body, "a")
 
 		for _, link := range links {
 			for index, attribute := range link.Attr {
-				if attribute.Key == "href" {
-					crossReferencedPage := slices.FindFunc(pages, func(page *Page) bool {
-						// Using cross-references in the source document involves
-						// adding the complete section heading (with spaces) into
-						// the "href" attribute. However, some tools, like Pandoc,
-						// replace spaces and other characters with query escape
-						// sequences. Thus, it is better to remove them before
-						// conducting a lookup, if that cross-reference, i.e.
-						// title exists in the pages slice.
-						referencedTitle, err := url.QueryUnescape(attribute.Val)
-						if err != nil {
-							return false // ignore error
-						}
-
-						return page.Title.Text == referencedTitle
-					})
-
-					if crossReferencedPage != nil {
-						// Using `attribute.Val = "..."` does not, as intended,
-						// update the attribute value. Thus, it is required to
-						// refer to the attribute by index to preserve the pointer.
-						// See: https://stackoverflow.com/a/63870840
-						link.Attr[index].Val = crossReferencedPage.Path
+				if attribute.Key != "href" {
+					continue
+				}
+
+				// Using `attribute.Val = "..."` does not, as intended,
+				// update the attribute value. Thus, it is required to
+				// refer to the attribute by index to preserve the pointer.
+				// See: https://stackoverflow.com/a/63870840
+				diagnostic := &Diagnostic{
+					Path:  page.Path,
+					Title: page.Title.Text,
+					Href:  attribute.Val,
+					Text:  strings.Join(strings.Fields(parsetree.Text(link)), " "),
+				}
+
+				if path, ok := resolveFragmentLink(page, attribute.Val, targets); ok {
+					link.Attr[index].Val = path
+
+					if linked := targets[getFragmentId(attribute.Val)]; linked.count > 1 {
+						diagnostic.Kind = AmbiguousLink
+						diagnostic.Message = fmt.Sprintf("id exists %d times, refers to '%s'", linked.count, path)
+						diagnostics = append(diagnostics, diagnostic)
+					}
+
+					continue
+				}
+
+				if strings.HasPrefix(attribute.Val, "#") && isInternalLink(attribute.Val) {
+					diagnostic.Kind = UnresolvedLink
+					diagnostic.Message = "no element with this id"
+					diagnostics = append(diagnostics, diagnostic)
+					continue
+				}
+
+				if count := countTitles(pages, attribute.Val); count > 1 {
+					diagnostic.Kind = AmbiguousLink
+					diagnostic.Message = fmt.Sprintf("%d pages or sections have this title", count)
+					diagnostics = append(diagnostics, diagnostic)
+				}
+
+				crossReferencedPage := slices.FindFunc(pages, func(page *Page) bool {
+					// Using cross-references in the source document involves
+					// adding the complete section heading (with spaces) into
+					// the "href" attribute. However, some tools, like Pandoc,
+					// replace spaces and other characters with query escape
+					// sequences. Thus, it is better to remove them before
+					// conducting a lookup, if that cross-reference, i.e.
+					// title exists in the pages slice.
+					referencedTitle, err := url.QueryUnescape(attribute.Val)
+					if err != nil {
+						return false // ignore error
+					}
+
+					return page.Title.Text == referencedTitle
+				})
+
+				if crossReferencedPage != nil {
+					link.Attr[index].Val = RelativeURL(page.Path, crossReferencedPage.Path, false)
+					continue
+				}
+
+				sectionPage, section := findSection(pages, attribute.Val)
+				if section != nil {
+					if sectionPage == page {
+						link.Attr[index].Val = "#" + section.Id
+					} else {
+						link.Attr[index].Val = RelativeURL(page.Path, sectionPage.Path+"#"+section.Id, false)
 					}
+					continue
 				}
+
+				if isInternalLink(attribute.Val) && !isPagePath(pages, attribute.Val) {
+					diagnostic.Kind = UnresolvedLink
+					diagnostic.Message = "no page or section with this title"
+					diagnostics = append(diagnostics, diagnostic)
+					continue
+				}
+
+				// Links to files, e.g. "files/data.csv", are relative to the output directory.
+				link.Attr[index].Val = RelativeURL(page.Path, attribute.Val, false)
 			}
 		}
 
+		// The URLs of the other elements, e.g. of images, are relative to the output directory, too.
+		RewriteURLs(body, func(element *html.Node, url string) string {
+			if parsetree.TagName(element) == "a" {
+				return url
+			}
+
+			return RelativeURL(page.Path, url, false)
+		})
+
 		html, err := parsetree.Html(parsetree.Children(body)...)
 		if err != nil {
-			return err
+			return diagnostics, err
 		}
 
 		page.Content.Html = html // update content via pointer
 	}
 
-	return nil
+	return diagnostics, nil
+}
+
+// resolveFragmentLink returns the path of the page containing the element referred to by
+// a fragment link, e.g. "page2.html#setup" for "#setup". Fragment links to elements on the
+// same page stay unchanged.
+func resolveFragmentLink(page *Page, href string, targets map[string]*target) (string, bool) {
+	if !strings.HasPrefix(href, "#") || len(href) == 1 {
+		return "", false
+	}
+
+	linked, ok := targets[getFragmentId(href)]
+	if !ok {
+		return "", false
+	}
+
+	if linked.isAnchor {
+		return RelativeURL(page.Path, linked.page.Path, false), true
+	}
+
+	if linked.page == page {
+		return href, true
+	}
+
+	return RelativeURL(page.Path, linked.page.Path+href, false), true
+}
+
+// getFragmentId returns the unescaped id of a fragment link, e.g. "über" for "#%C3%BCber".
+func getFragmentId(href string) string {
+	id, err := url.PathUnescape(strings.TrimPrefix(href, "#"))
+	if err != nil {
+		return strings.TrimPrefix(href, "#")
+	}
+
+	return id
+}
+
+// countTitles returns the number of pages and sections with the title referred to by the
+// given cross-reference.
+func countTitles(pages []*Page, crossReference string) int {
+	referencedTitle, err := url.QueryUnescape(crossReference)
+	if err != nil {
+		return 0
+	}
+
+	count := 0
+
+	for _, page := range pages {
+		if page.Title.Text == referencedTitle {
+			count++
+		}
+
+		for _, section := range page.Sections {
+			if section.Title.Text == referencedTitle {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+// isPagePath reports, whether the link refers to a page by its path, e.g. "page2.html".
+func isPagePath(pages []*Page, href string) bool {
+	path, _, _ := strings.Cut(href, "#")
+
+	for _, page := range pages {
+		if page.Path == path || strings.TrimSuffix(page.Path, indexFileName) == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findSection returns the section whose title is referred to by the given cross-reference
+// together with its page, or nil.
+func findSection(pages []*Page, crossReference string) (*Page, *Section) {
+	referencedTitle, err := url.QueryUnescape(crossReference)
+	if err != nil {
+		return nil, nil // ignore error
+	}
+
+	for _, page := range pages {
+		for _, section := range page.Sections {
+			if section.Title.Text == referencedTitle {
+				return page, section
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// getIds recursively returns the id attributes of all elements of a node.
+func getIds(node *html.Node) []string {
+	var ids []string
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if parsetree.IsElement(child) {
+			if id, ok := parsetree.AttributeMap(child)["id"]; ok && id != "" {
+				ids = append(ids, id)
+			}
+		}
+
+		ids = append(ids, getIds(child)...)
+	}
+
+	return ids
 }
@@ -0,0 +1,117 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package book
+
+import (
+	"fmt"
+	"path"
+)
+
+// Translation is the same book or page in another language, e.g. for a language switcher.
+type Translation struct {
+	Language string // e.g. "en", which is also the directory of the language in the output directory
+	Title    string // title of the book or page in the language
+	Path     string // path of the page in the directory of the language, e.g. "page1.html"
+}
+
+// LinkTranslations sets the translations of the books of a multilingual book and of their pages.
+// Every book must have its language in the meta data. Pages are matched by the id of their heading,
+// or else by their position. It returns a diagnostic for every page without translation into one
+// of the other languages, with the path of the page prefixed by its language, e.g. "de/page3.html".
+func LinkTranslations(books []*Book) []*Diagnostic {
+	var diagnostics []*Diagnostic
+
+	for _, b := range books {
+		b.Translations = nil
+
+		for _, page := range b.Pages {
+			page.Translations = nil
+		}
+	}
+
+	for index, b := range books {
+		for _, other := range books[index+1:] {
+			b.Translations = append(b.Translations, getBookTranslation(other))
+			other.Translations = append(other.Translations, getBookTranslation(b))
+
+			for page, translated := range matchPages(b.Pages, other.Pages) {
+				page.Translations = append(page.Translations, getPageTranslation(other, translated))
+				translated.Translations = append(translated.Translations, getPageTranslation(b, page))
+			}
+		}
+	}
+
+	// Reported in the order of the languages and pages.
+	for _, b := range books {
+		for _, page := range b.Pages {
+			for _, other := range books {
+				if other != b && !hasTranslation(page, other.MetaData.Language) {
+					diagnostics = append(diagnostics, &Diagnostic{
+						Kind:    UntranslatedPage,
+						Path:    path.Join(b.MetaData.Language, page.Path),
+						Title:   page.Title.Text,
+						Message: fmt.Sprintf("no translation into language '%s'", other.MetaData.Language),
+					})
+				}
+			}
+		}
+	}
+
+	return diagnostics
+}
+
+// matchPages returns the translated page for every page, which has one. Pages with the same heading
+// id match, the remaining pages match the page at the same position, unless it matches another page.
+func matchPages(pages []*Page, translatedPages []*Page) map[*Page]*Page {
+	matches := make(map[*Page]*Page)
+	matched := make(map[*Page]bool) // key: translated page
+
+	byAnchor := make(map[string]*Page)
+	for _, translated := range translatedPages {
+		if translated.Anchor != "" {
+			byAnchor[translated.Anchor] = translated
+		}
+	}
+
+	for _, page := range pages {
+		if translated, ok := byAnchor[page.Anchor]; ok && page.Anchor != "" && !matched[translated] {
+			matches[page] = translated
+			matched[translated] = true
+		}
+	}
+
+	for index, page := range pages {
+		if _, ok := matches[page]; ok || index >= len(translatedPages) {
+			continue
+		}
+
+		if translated := translatedPages[index]; !matched[translated] {
+			matches[page] = translated
+			matched[translated] = true
+		}
+	}
+
+	return matches
+}
+
+func getBookTranslation(b *Book) *Translation {
+	return &Translation{Language: b.MetaData.Language, Title: b.MetaData.Title, Path: indexFileName}
+}
+
+func getPageTranslation(b *Book, page *Page) *Translation {
+	return &Translation{Language: b.MetaData.Language, Title: page.Title.Text, Path: page.Path}
+}
+
+func hasTranslation(page *Page, language string) bool {
+	for _, translation := range page.Translations {
+		if translation.Language == language {
+			return true
+		}
+	}
+
+	return false
+}
@@ -7,129 +7,423 @@
 package bookprint
 
 import (
-	"html/template"
+	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"stefanco.de/bookprint/internal/book"
+	utilFs "stefanco.de/bookprint/internal/util/fs"
+	"stefanco.de/bookprint/internal/util/slices"
 )
 
+// DefaultFormat is the output format used when no formats are configured.
+const DefaultFormat = "html"
+
 type Config struct {
-	File        []byte
-	OutputDir   string
-	TemplateDir string
+	File         []byte
+	OutputDir    string
+	TemplateDir  string
+	StaticDir    string
+	Formats      []string
+	SplitLevel   int                          // headings up to this level start a new page, see book.Options
+	Numbering    *book.Numbering              // prefixes of the headings, see book.Numbering
+	Paths        string                       // path strategy or pattern of the pages, see book.Options
+	Layout       string                       // layout of the pages in the output directory, see book.Options
+	MetaData     *book.MetaData               // overrides the non-empty meta data of the book
+	Options      map[string]map[string]string // format-specific options, key: format, value: options by name
+	Translations map[string]map[string]string // overrides the texts of the templates, key: language, value: texts by key
+	Log          io.Writer                    // receives warnings, e.g. about broken cross-references; nil discards them
+	Strict       bool                         // treat warnings as errors
+	Outputs      map[string]bool              // if not nil, receives the paths of all rendered files, relative to the output directory
+	Clean        bool                         // recreate the output directory and copy the static directory into it before rendering
+	Languages    []*Language                  // inputs of a multilingual book, which replace File, see Language
+
+	languageDir string // directory of the language in the output directory, see Language
+}
+
+// Language is the input of one language of a multilingual book. Every language is rendered into
+// its own directory of the output directory, named by its code, e.g. "out/de" and "out/en".
+type Language struct {
+	Code string // e.g. "de", which replaces the language of the meta data
+	File []byte
+}
+
+// Option returns the value of a format-specific option, or the default value if not set.
+func (c *Config) Option(format string, name string, defaultValue string) string {
+	if value, ok := c.Options[format][name]; ok {
+		return value
+	}
+
+	return defaultValue
 }
 
-// ToDo: Show debug info when a template is not existent.
-// ToDo: Check if it would be reliable to skip not existent templates.
+// Renderer renders a book into the output directory of the given configuration.
+type Renderer interface {
+	Render(b *book.Book, config *Config) error
+}
 
+// OptionRenderer is a renderer supporting format-specific options.
+type OptionRenderer interface {
+	Renderer
+	Options() []string
+}
+
+// CheckRenderer is a renderer, which checks the configuration before the output directory
+// is touched, e.g. whether its templates exist and can be parsed.
+type CheckRenderer interface {
+	Renderer
+	Check(b *book.Book, config *Config) error
+}
+
+var renderers = map[string]Renderer{
+	"html": &htmlRenderer{},
+	"epub": &epubRenderer{},
+	"json": &jsonRenderer{},
+}
+
+// New creates the book from the input file of the configuration, or a book for every language,
+// and renders it in all formats.
+// The book and the renderers are checked before the output directory is prepared, so that
+// errors leave the output directory of the previous build untouched.
 func New(config *Config) error {
-	b, err := book.New(config.File)
-	if err != nil {
-		return err
+	formats := config.Formats
+	if slices.IsEmpty(formats) {
+		formats = []string{DefaultFormat}
 	}
 
-	err = createIndex(b, config)
+	err := CheckFormats(formats)
 	if err != nil {
 		return err
 	}
 
-	err = createMap(b, config)
+	err = CheckOptions(config.Options)
 	if err != nil {
 		return err
 	}
 
-	err = createPages(b, config)
+	err = checkMetaData(config.MetaData)
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
+	var codes []string
+	for _, language := range config.Languages {
+		codes = append(codes, language.Code)
+	}
 
-func createIndex(b *book.Book, config *Config) error {
-	templateFile := filepath.Join(config.TemplateDir, "index.html")
-	templateFileName := filepath.Base(templateFile)
+	err = CheckLanguages(codes)
+	if err != nil {
+		return err
+	}
 
-	outputFile, err := os.Create(filepath.Join(config.OutputDir, templateFileName))
+	_, err = getBuildTime()
 	if err != nil {
 		return err
 	}
 
-	t, err := template.New(templateFileName).ParseFiles(templateFile)
+	books, configs, err := createBooks(config)
 	if err != nil {
 		return err
 	}
 
-	err = t.Execute(outputFile, b)
+	var diagnostics []*book.Diagnostic
+	for _, b := range books {
+		diagnostics = append(diagnostics, b.Diagnostics...)
+	}
+
+	if len(books) > 1 {
+		diagnostics = append(diagnostics, book.LinkTranslations(books)...)
+	}
+
+	err = report(diagnostics, config)
 	if err != nil {
 		return err
 	}
 
-	err = outputFile.Close()
+	// The languages share the templates, so that checking the first language suffices.
+	for _, format := range formats {
+		if renderer, ok := renderers[format].(CheckRenderer); ok {
+			err = renderer.Check(books[0], configs[0])
+			if err != nil {
+				return fmt.Errorf("checking format '%s' failed: %w", format, err)
+			}
+		}
+	}
+
+	err = prepareOutputDir(config)
 	if err != nil {
 		return err
 	}
 
+	for index, b := range books {
+		for _, format := range formats {
+			err = renderers[format].Render(b, configs[index])
+			if err != nil {
+				return fmt.Errorf("rendering format '%s' failed: %w", format, configs[index].languageError(err))
+			}
+		}
+	}
+
 	return nil
 }
 
-func createMap(b *book.Book, config *Config) error {
-	templateFile := filepath.Join(config.TemplateDir, "map.html")
-	templateFileName := filepath.Base(templateFile)
+// createBooks creates the book from the input file, or a book for every language of a multilingual
+// book. It returns the books with the configuration of their language.
+func createBooks(config *Config) ([]*book.Book, []*Config, error) {
+	if len(config.Languages) == 0 {
+		b, err := createBook(config.File, config)
+		if err != nil {
+			return nil, nil, err
+		}
 
-	outputFile, err := os.Create(filepath.Join(config.OutputDir, templateFileName))
-	if err != nil {
-		return err
+		return []*book.Book{b}, []*Config{config}, nil
 	}
 
-	t, err := template.New(templateFileName).ParseFiles(templateFile)
-	if err != nil {
-		return err
+	var books []*book.Book
+	var configs []*Config
+
+	for _, language := range config.Languages {
+		languageConfig := *config
+		languageConfig.languageDir = language.Code
+
+		b, err := createBook(language.File, &languageConfig)
+		if err != nil {
+			return nil, nil, languageConfig.languageError(err)
+		}
+
+		b.MetaData.Language = language.Code
+
+		// Diagnostics name the page with the directory of its language, e.g. "de/page1.html".
+		for _, diagnostic := range b.Diagnostics {
+			diagnostic.Path = path.Join(language.Code, diagnostic.Path)
+		}
+
+		books = append(books, b)
+		configs = append(configs, &languageConfig)
 	}
 
-	err = t.Execute(outputFile, b)
+	return books, configs, nil
+}
+
+// createBook creates a book from an input file and overrides its meta data.
+func createBook(file []byte, config *Config) (*book.Book, error) {
+	b, err := book.New(file, &book.Options{
+		SplitLevel:   config.SplitLevel,
+		Numbering:    config.Numbering,
+		PathStrategy: config.Paths,
+		Layout:       config.Layout,
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = outputFile.Close()
-	if err != nil {
+	if config.MetaData != nil {
+		setMetaData(b.MetaData, config.MetaData)
+	}
+
+	return b, nil
+}
+
+// languageError adds the language to an error of a multilingual book.
+func (c *Config) languageError(err error) error {
+	if c.languageDir == "" {
 		return err
 	}
 
+	return fmt.Errorf("language '%s': %w", c.languageDir, err)
+}
+
+// CheckLanguages returns an error if a language code is empty, no valid directory name
+// or used twice.
+func CheckLanguages(codes []string) error {
+	for index, code := range codes {
+		if code == "" || code == "." || code == ".." || strings.ContainsAny(code, `/\:`) {
+			return fmt.Errorf("invalid language '%s', e.g. use 'de' or 'en-US'", code)
+		}
+
+		if slices.Contains(codes[:index], code) {
+			return fmt.Errorf("language '%s' is used twice", code)
+		}
+	}
+
 	return nil
 }
 
-func createPages(b *book.Book, config *Config) error {
-	templateFile := filepath.Join(config.TemplateDir, "page.html")
-	templateFileName := filepath.Base(templateFile)
+// LanguageDirs returns the directories of the languages of a multilingual book, relative to the
+// output directory, or "." for a book with a single language.
+func (c *Config) LanguageDirs() []string {
+	if len(c.Languages) == 0 {
+		return []string{"."}
+	}
 
-	t, err := template.New(templateFileName).ParseFiles(templateFile)
-	if err != nil {
-		return err
+	var directories []string
+	for _, language := range c.Languages {
+		directories = append(directories, language.Code)
 	}
 
-	type Page struct {
-		MetaData *book.MetaData
-		Page     *book.Page
+	return directories
+}
+
+// Register makes a renderer available under the given output format name.
+// An already registered renderer with the same name is replaced.
+func Register(format string, renderer Renderer) {
+	renderers[format] = renderer
+}
+
+// Formats returns the names of all available output formats in alphabetical order.
+func Formats() []string {
+	var formats []string
+
+	for format := range renderers {
+		formats = append(formats, format)
 	}
 
-	for _, page := range b.Pages {
-		outputFile, err := os.Create(filepath.Join(config.OutputDir, page.Path))
+	sort.Strings(formats)
+
+	return formats
+}
+
+// CheckFormats returns an error if one of the given output formats is not available.
+func CheckFormats(formats []string) error {
+	for _, format := range formats {
+		if _, ok := renderers[format]; !ok {
+			return fmt.Errorf("unknown format '%s', available formats are: %s", format, strings.Join(Formats(), ", "))
+		}
+	}
+
+	return nil
+}
+
+// CheckOptions returns an error if one of the given format-specific options is not
+// supported by the renderer of the format.
+func CheckOptions(options map[string]map[string]string) error {
+	var formats []string
+	for format := range options {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+
+	for _, format := range formats {
+		err := CheckFormats([]string{format})
+		if err != nil {
+			return fmt.Errorf("invalid options: %w", err)
+		}
+
+		var names []string
+		if renderer, ok := renderers[format].(OptionRenderer); ok {
+			names = renderer.Options()
+		}
+
+		for name := range options[format] {
+			if slices.Contains(names, name) {
+				continue
+			}
+
+			if len(names) == 0 {
+				return fmt.Errorf("unknown option '%s', format '%s' has no options", name, format)
+			}
+
+			return fmt.Errorf("unknown option '%s' for format '%s', available options are: %s", name, format, strings.Join(names, ", "))
+		}
+	}
+
+	return nil
+}
+
+// prepareOutputDir creates the output directory and the directories of the languages. With Clean,
+// an existing output directory is removed before, and the files of the static directory are copied
+// into it, or into the directory of every language of a multilingual book.
+func prepareOutputDir(config *Config) error {
+	if config.Clean {
+		err := utilFs.RemoveDir(config.OutputDir)
 		if err != nil {
 			return err
 		}
+	}
+
+	for _, directory := range config.LanguageDirs() {
+		outputDir := filepath.Join(config.OutputDir, directory)
 
-		err = t.Execute(outputFile, &Page{
-			MetaData: b.MetaData,
-			Page:     page,
-		})
+		err := utilFs.MakeDir(outputDir)
 		if err != nil {
 			return err
 		}
 
-		err = outputFile.Close()
+		if config.Clean && config.StaticDir != "" {
+			err = utilFs.CopyDir(config.StaticDir, outputDir)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// note writes a note to the log, e.g. about a skipped template.
+func (c *Config) note(format string, arguments ...any) {
+	if c.Log != nil {
+		_, _ = fmt.Fprintf(c.Log, "Note: %s\n", fmt.Sprintf(format, arguments...))
+	}
+}
+
+// report writes the diagnostics of the book as warnings to the log. In strict mode,
+// diagnostics are errors and prevent rendering the book.
+func report(diagnostics []*book.Diagnostic, config *Config) error {
+	if config.Log != nil {
+		for _, diagnostic := range diagnostics {
+			_, err := fmt.Fprintf(config.Log, "Warning: %s\n", diagnostic)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if config.Strict && len(diagnostics) > 0 {
+		return fmt.Errorf("found %d problem(s) in strict mode", len(diagnostics))
+	}
+
+	return nil
+}
+
+// getBuildTime returns the time of the build, i.e. the time of the SOURCE_DATE_EPOCH environment
+// variable for reproducible builds, or else the current time.
+// See: https://reproducible-builds.org/docs/source-date-epoch/
+func getBuildTime() (time.Time, error) {
+	epoch, ok := os.LookupEnv("SOURCE_DATE_EPOCH")
+	if !ok || epoch == "" {
+		return time.Now(), nil
+	}
+
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("SOURCE_DATE_EPOCH '%s' is no number of seconds since 1970-01-01", epoch)
+	}
+
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// checkMetaData returns an error, if a contributor of the meta data has no name or an unknown role,
+// or if a date has an unsupported format.
+func checkMetaData(metaData *book.MetaData) error {
+	if metaData == nil {
+		return nil
+	}
+
+	for _, date := range []*book.Date{metaData.Date, metaData.Published, metaData.Modified} {
+		if date != nil && !date.IsValid() {
+			return fmt.Errorf("date '%s' has an unsupported format, e.g. use '2006-01-02'", date.Text)
+		}
+	}
+
+	for _, contributor := range metaData.Contributors {
+		err := contributor.Check()
 		if err != nil {
 			return err
 		}
@@ -137,3 +431,68 @@ func createPages(b *book.Book, config *Config) error {
 
 	return nil
 }
+
+// setMetaData replaces the meta data of the book with the non-empty overrides.
+func setMetaData(metaData *book.MetaData, overrides *book.MetaData) {
+	fields := []struct {
+		value    *string
+		override string
+	}{
+		{&metaData.Title, overrides.Title},
+		{&metaData.Description, overrides.Description},
+		{&metaData.Language, overrides.Language},
+		{&metaData.Publisher, overrides.Publisher},
+		{&metaData.Rights, overrides.Rights},
+		{&metaData.Identifier, overrides.Identifier},
+		{&metaData.ISBN, overrides.ISBN},
+		{&metaData.Series, overrides.Series},
+		{&metaData.SeriesIndex, overrides.SeriesIndex},
+		{&metaData.Cover, overrides.Cover},
+	}
+
+	for _, field := range fields {
+		if field.override != "" {
+			*field.value = field.override
+		}
+	}
+
+	dates := []struct {
+		value    **book.Date
+		override *book.Date
+	}{
+		{&metaData.Date, overrides.Date},
+		{&metaData.Published, overrides.Published},
+		{&metaData.Modified, overrides.Modified},
+	}
+
+	for _, date := range dates {
+		if date.override != nil {
+			*date.value = date.override
+		}
+	}
+
+	if len(overrides.Contributors) > 0 {
+		metaData.SetContributors(overrides.Contributors)
+	}
+
+	// The author replaces the authors, but keeps the other contributors.
+	if overrides.Author != "" {
+		contributors := []*book.Contributor{{Name: overrides.Author, Role: book.AuthorRole}}
+
+		for _, contributor := range metaData.Contributors {
+			if contributor.Role != book.AuthorRole {
+				contributors = append(contributors, contributor)
+			}
+		}
+
+		metaData.SetContributors(contributors)
+	}
+
+	if len(overrides.Keywords) > 0 {
+		metaData.Keywords = overrides.Keywords
+	}
+
+	if len(overrides.Subjects) > 0 {
+		metaData.Subjects = overrides.Subjects
+	}
+}
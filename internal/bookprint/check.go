@@ -0,0 +1,381 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package bookprint
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template/parse"
+
+	"stefanco.de/bookprint/internal/book"
+	"stefanco.de/bookprint/internal/search"
+	utilFs "stefanco.de/bookprint/internal/util/fs"
+	"stefanco.de/bookprint/internal/util/slices"
+)
+
+// sampleBook is the source document of the sample books used by CheckTemplates. Its pages cover
+// the edge cases of the templates: The first page has no parents and no previous page, the
+// nested page has no children, and the last page has no next page, no parents and no children.
+const sampleBook = `<!DOCTYPE html>
+<html>
+<head><title>%s</title>%s</head>
+<body>
+%s
+<h1 id="first">First Chapter</h1>
+<p>The first page links to the <a href="#last">last page</a>.</p>
+<h2 id="nested">Nested Chapter</h2>
+<p>The nested page has a section.</p>
+<h3 id="section">Section</h3>
+<p>Text of the section.</p>
+<h1 id="last">Last Chapter</h1>
+<p>The last page.</p>
+</body>
+</html>`
+
+// sampleSplitLevel is the split level of the sample books, so that they have nested pages and sections.
+const sampleSplitLevel = 2
+
+// TemplateProblem is a problem of a template found by CheckTemplates.
+type TemplateProblem struct {
+	Location string // file name and position, e.g. "templates/page.html:12:9"
+	Message  string
+}
+
+func (p *TemplateProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.Location, p.Message)
+}
+
+// templateErrorRegexp matches the location of template errors, e.g. "template: page.html:12:9: ...".
+var templateErrorRegexp = regexp.MustCompile(`(?s)^template: ([^:]+):(\d+(?::\d+)?): (.*)$`)
+
+// templateChecker collects the problems of the templates.
+type templateChecker struct {
+	config     *Config
+	problems   []*TemplateProblem
+	seen       map[string]bool // key: problem, to report every problem once
+	references map[string]bool // key: name of a template used by the rendered templates
+}
+
+// CheckTemplates parses all templates of the HTML output and executes them against sample books
+// with and without meta data. It returns the number of checked templates and the problems found,
+// i.e. missing required templates, syntax errors, unknown fields, which are also resolved against
+// the types of the data without execution, execution errors like missing map keys, and partials,
+// which are never used. The output directory is not touched.
+func CheckTemplates(config *Config) (int, []*TemplateProblem, error) {
+	if config.TemplateDir != "" && !utilFs.ExistDir(config.TemplateDir) {
+		return 0, nil, fmt.Errorf("template directory '%s' does not exist", config.TemplateDir)
+	}
+
+	err := checkMetaData(config.MetaData)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Assets are written into a temporary output directory.
+	outputDir, err := os.MkdirTemp("", "bookprint-check-")
+	if err != nil {
+		return 0, nil, err
+	}
+	defer os.RemoveAll(outputDir)
+
+	checkConfig := *config
+	checkConfig.OutputDir = outputDir
+	checkConfig.Outputs = nil
+
+	checker := &templateChecker{
+		config:     &checkConfig,
+		seen:       make(map[string]bool),
+		references: make(map[string]bool),
+	}
+
+	books, err := getSampleBooks(&checkConfig)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	names, err := getTemplateNames(&checkConfig)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	sharedNames, err := getSharedTemplateNames(&checkConfig)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	for _, name := range requiredTemplates {
+		if !slices.Contains(names, name) {
+			checker.add(name, "", fmt.Errorf("required template does not exist"))
+		}
+	}
+
+	err = checkOutputPaths(names, books[0].Pages)
+	if err != nil {
+		checker.add(".", "", err)
+	}
+
+	// Only the book with meta data has a search index.
+	contexts := []string{"book with meta data", "book without meta data"}
+	searchIndexes := []string{search.FileName, ""}
+
+	for index, b := range books {
+		shared, err := parseSharedTemplates(b, &checkConfig)
+		if err != nil {
+			// Without shared templates, the other templates cannot be checked.
+			checker.add(".", "", err)
+			break
+		}
+
+		for _, name := range names {
+			checker.checkTemplate(name, b, shared, contexts[index], searchIndexes[index])
+		}
+	}
+
+	if len(checker.references) > 0 {
+		err = checker.checkUsage(sharedNames)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	return len(names) + len(sharedNames), checker.problems, nil
+}
+
+// getSampleBooks returns a sample book with the meta data of the configuration and translations,
+// and one without any meta data and translations.
+func getSampleBooks(config *Config) ([]*book.Book, error) {
+	options := &book.Options{
+		SplitLevel:   sampleSplitLevel,
+		Numbering:    config.Numbering,
+		PathStrategy: config.Paths,
+		Layout:       config.Layout,
+	}
+
+	withMetaData, err := book.New([]byte(fmt.Sprintf(sampleBook, "Sample Book",
+		`<meta name="author" content="Jane Doe" data-sort-name="Doe, Jane" data-affiliation="Sample University">`+
+			`<meta name="author" content="John Roe"><meta name="editor" content="Erika Mustermann">`+
+			`<meta name="translator" content="Max Mustermann"><meta name="dcterms.date" content="2023-06-01">`+
+			`<meta name="dcterms.issued" content="2023-07-01"><meta name="dcterms.modified" content="2023-08-01T12:00:00Z">`+
+			`<meta name="dcterms.language" content="en"><meta name="description" content="A sample book.">`+
+			`<meta name="keywords" content="sample, book"><meta name="dcterms.subject" content="Samples">`+
+			`<meta name="dcterms.publisher" content="Sample Press"><meta name="dcterms.rights" content="CC BY 4.0">`+
+			`<meta name="dcterms.identifier" content="urn:isbn:978-3-16-148410-0"><meta name="series" content="Samples">`+
+			`<meta name="series-index" content="1"><meta name="cover" content="cover.png">`,
+		"<p>The preface of the sample book.</p>")), options)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.MetaData != nil {
+		setMetaData(withMetaData.MetaData, config.MetaData)
+	}
+
+	// Translations like those of a multilingual book, e.g. for a language switcher.
+	withMetaData.Translations = []*book.Translation{{Language: "de", Title: "Beispielbuch", Path: "index.html"}}
+	for _, page := range withMetaData.Pages {
+		page.Translations = []*book.Translation{{Language: "de", Title: page.Title.Text, Path: page.Path}}
+	}
+
+	withoutMetaData, err := book.New([]byte(fmt.Sprintf(sampleBook, "", "", "")), options)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*book.Book{withMetaData, withoutMetaData}, nil
+}
+
+// checkTemplate parses a template and executes it, once with the book or for every page.
+func (c *templateChecker) checkTemplate(name string, b *book.Book, shared *template.Template, context string, searchIndex string) {
+	t, err := parseTemplate(name, shared, c.config)
+	if err != nil {
+		c.add(name, "", err)
+		return
+	}
+
+	// Before the first execution, which escapes the templates and changes their trees.
+	c.addReferences(t, name)
+
+	// Execution stops at the first error and skips branches, which the sample books don't reach.
+	data := reflect.TypeOf(&Book{})
+	if isPageTemplate(name) {
+		data = reflect.TypeOf(&Page{})
+	}
+
+	for _, err := range checkFields(t, name, data, getFuncMap(b, c.config)) {
+		c.add(name, "", err)
+	}
+
+	t.Option("missingkey=error")
+
+	if !isPageTemplate(name) {
+		metaData, err := getOutputMetaData(b.MetaData, name, ".", b.Pages, c.config.Layout == book.NestedLayout)
+		if err != nil {
+			c.add(name, "", err)
+			return
+		}
+
+		outputBook := *b
+		outputBook.MetaData = metaData
+
+		err = t.Execute(io.Discard, &Book{
+			Book:        &outputBook,
+			Path:        name,
+			SearchIndex: searchIndex,
+			Translations: getTranslations(b.Translations, func(*book.Translation) string {
+				return name
+			}),
+		})
+		if err != nil {
+			c.add(name, context, err)
+		}
+
+		return
+	}
+
+	for _, page := range b.Pages {
+		outputPath := path.Join(path.Dir(name), page.Path)
+
+		page, err = getOutputPage(page, path.Dir(name), b.Pages, c.config.Layout == book.NestedLayout)
+		if err != nil {
+			c.add(name, "", err)
+			return
+		}
+
+		metaData, err := getOutputMetaData(b.MetaData, outputPath, path.Dir(name), b.Pages, c.config.Layout == book.NestedLayout)
+		if err != nil {
+			c.add(name, "", err)
+			return
+		}
+
+		err = t.Execute(io.Discard, &Page{
+			MetaData:    metaData,
+			Page:        page,
+			Path:        outputPath,
+			SearchIndex: searchIndex,
+			Translations: getTranslations(page.Translations, func(translation *book.Translation) string {
+				return path.Join(path.Dir(name), translation.Path)
+			}),
+		})
+		if err != nil {
+			c.add(name, fmt.Sprintf("%s, page '%s'", context, page.Title.Text), err)
+		}
+	}
+}
+
+// addReferences adds the name of the template and the names of all templates called by it,
+// directly or indirectly, to the references.
+func (c *templateChecker) addReferences(t *template.Template, name string) {
+	if c.references[name] {
+		return
+	}
+
+	c.references[name] = true
+
+	called := t.Lookup(name)
+	if called == nil || called.Tree == nil {
+		return
+	}
+
+	for _, calledName := range getCalledTemplates(called.Tree.Root) {
+		c.addReferences(t, calledName)
+	}
+}
+
+// checkUsage reports the files of the shared template directories, which are neither used
+// themselves, nor by one of the templates defined within.
+func (c *templateChecker) checkUsage(sharedNames []string) error {
+	for _, name := range sharedNames {
+		content, err := fs.ReadFile(getTemplateFS(c.config), name)
+		if err != nil {
+			return err
+		}
+
+		tree := parse.New(name)
+		tree.Mode = parse.SkipFuncCheck
+
+		trees := make(map[string]*parse.Tree)
+		_, err = tree.Parse(string(content), "", "", trees)
+		if err != nil {
+			continue // already reported
+		}
+
+		used := c.references[name]
+		for definedName := range trees {
+			used = used || c.references[definedName]
+		}
+
+		if !used {
+			c.add(name, "", fmt.Errorf("partial is never used"))
+		}
+	}
+
+	return nil
+}
+
+// add adds the problem of an error, unless it was already added.
+func (c *templateChecker) add(name string, context string, err error) {
+	problem := &TemplateProblem{
+		Location: filepath.Join(c.config.TemplateDir, filepath.FromSlash(name)),
+		Message:  strings.TrimPrefix(err.Error(), "template: "),
+	}
+
+	if matches := templateErrorRegexp.FindStringSubmatch(err.Error()); matches != nil {
+		problem.Location = filepath.Join(c.config.TemplateDir, filepath.FromSlash(matches[1])) + ":" + matches[2]
+		problem.Message = matches[3]
+	}
+
+	key := problem.String()
+	if c.seen[key] {
+		return
+	}
+
+	c.seen[key] = true
+
+	if context != "" {
+		problem.Message += " (" + context + ")"
+	}
+
+	c.problems = append(c.problems, problem)
+}
+
+// getCalledTemplates returns the names of the templates called within the node,
+// e.g. "header" for {{template "header" .}} or {{block "header" .}}{{end}}.
+func getCalledTemplates(node parse.Node) []string {
+	var names []string
+
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return nil
+		}
+
+		for _, child := range node.Nodes {
+			names = append(names, getCalledTemplates(child)...)
+		}
+	case *parse.TemplateNode:
+		names = append(names, node.Name)
+	case *parse.IfNode:
+		names = append(names, getCalledTemplates(node.List)...)
+		names = append(names, getCalledTemplates(node.ElseList)...)
+	case *parse.RangeNode:
+		names = append(names, getCalledTemplates(node.List)...)
+		names = append(names, getCalledTemplates(node.ElseList)...)
+	case *parse.WithNode:
+		names = append(names, getCalledTemplates(node.List)...)
+		names = append(names, getCalledTemplates(node.ElseList)...)
+	}
+
+	return names
+}
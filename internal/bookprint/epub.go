@@ -0,0 +1,670 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package bookprint
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	textTemplate "text/template"
+	"time"
+
+	"golang.org/x/net/html"
+
+	"stefanco.de/bookprint/internal/book"
+	"stefanco.de/bookprint/internal/i18n"
+	"stefanco.de/bookprint/internal/util/parsetree"
+	"stefanco.de/bookprint/internal/util/slices"
+)
+
+const epubFileName = "book.epub"
+
+// epubLanguage is the language of the book, if neither set by the "language" option nor by the meta data.
+const epubLanguage = "en"
+
+// epubRoot is the directory inside the EPUB container holding the package document,
+// the navigation document, the content documents and all static files.
+const epubRoot = "EPUB"
+
+const epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="EPUB/package.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+const epubPackage = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid" xml:lang="{{xml .Language}}">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">{{xml .Identifier}}</dc:identifier>
+    <dc:title>{{xml .MetaData.Title}}</dc:title>
+    <dc:language>{{xml .Language}}</dc:language>
+{{- range $index, $contributor := .MetaData.Contributors}}
+{{- $element := "dc:contributor"}}{{if eq .Role "author"}}{{$element = "dc:creator"}}{{end}}
+    <{{$element}} id="contributor-{{$index}}">{{xml .Name}}</{{$element}}>
+    <meta refines="#contributor-{{$index}}" property="role" scheme="marc:relators">{{.RoleCode}}</meta>
+{{- if .SortName}}
+    <meta refines="#contributor-{{$index}}" property="file-as">{{xml .SortName}}</meta>
+{{- end}}
+{{- end}}
+{{- if .Date}}
+    <dc:date>{{xml .Date}}</dc:date>
+{{- end}}
+{{- if .MetaData.Description}}
+    <dc:description>{{xml .MetaData.Description}}</dc:description>
+{{- end}}
+{{- if .MetaData.Publisher}}
+    <dc:publisher>{{xml .MetaData.Publisher}}</dc:publisher>
+{{- end}}
+{{- if .MetaData.Rights}}
+    <dc:rights>{{xml .MetaData.Rights}}</dc:rights>
+{{- end}}
+{{- range .Subjects}}
+    <dc:subject>{{xml .}}</dc:subject>
+{{- end}}
+{{- if .MetaData.Series}}
+    <meta property="belongs-to-collection" id="series">{{xml .MetaData.Series}}</meta>
+    <meta refines="#series" property="collection-type">series</meta>
+{{- if .MetaData.SeriesIndex}}
+    <meta refines="#series" property="group-position">{{xml .MetaData.SeriesIndex}}</meta>
+{{- end}}
+{{- end}}
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+  </metadata>
+  <manifest>
+{{- range .Items}}
+    <item id="{{xml .Id}}" href="{{xml .Href}}" media-type="{{xml .MediaType}}"{{if .Properties}} properties="{{.Properties}}"{{end}}/>
+{{- end}}
+  </manifest>
+  <spine{{if eq (direction .Language) "rtl"}} page-progression-direction="rtl"{{end}}>
+{{- range .Spine}}
+    <itemref idref="{{xml .}}"/>
+{{- end}}
+  </spine>
+</package>
+`
+
+const epubDocument = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xmlns:xlink="http://www.w3.org/1999/xlink" lang="{{xml .Language}}" xml:lang="{{xml .Language}}" dir="{{direction .Language}}">
+<head>
+  <meta charset="utf-8" />
+  <title>{{xml .Title}}</title>
+{{- range .Stylesheets}}
+  <link rel="stylesheet" type="text/css" href="{{xml .}}" />
+{{- end}}
+</head>
+<body{{if .Type}} epub:type="{{.Type}}"{{end}}>
+{{.Body}}
+</body>
+</html>
+`
+
+type epubItem struct {
+	Id         string
+	Href       string
+	MediaType  string
+	Properties string
+}
+
+type epubPackageData struct {
+	Identifier string
+	Language   string
+	Date       string // ISO 8601 publication date
+	Modified   string
+	MetaData   *book.MetaData
+	Subjects   []string // subjects and keywords of the meta data
+	Items      []*epubItem
+	Spine      []string
+}
+
+// epubWriter writes the files of the EPUB container with the same modification time.
+type epubWriter struct {
+	*zip.Writer
+	modified time.Time
+}
+
+type epubDocumentData struct {
+	Language    string
+	Title       string
+	Type        string
+	Stylesheets []string
+	Body        string
+}
+
+var epubTemplates = textTemplate.Must(textTemplate.New("epub").Funcs(textTemplate.FuncMap{
+	"xml":       xmlEscape,
+	"direction": i18n.Direction,
+}).Parse(`{{define "package"}}` + epubPackage + `{{end}}{{define "document"}}` + epubDocument + `{{end}}`))
+
+// epubRenderer packages the book as EPUB 3 file.
+type epubRenderer struct{}
+
+func (r *epubRenderer) Render(b *book.Book, config *Config) error {
+	return createEpub(b, config)
+}
+
+// Options returns the supported options: "file" is the name of the EPUB file and
+// "language" the language of the book, e.g. "de", which defaults to the language of the meta data.
+func (r *epubRenderer) Options() []string {
+	return []string{"file", "language"}
+}
+
+// createEpub packages the book as EPUB 3 file into the output directory. Every page becomes
+// its own XHTML content document with the extension ".xhtml", and all files from the static
+// directory are added as manifest items. The cover image of the meta data is marked as such, if it is a static file.
+func createEpub(b *book.Book, config *Config) error {
+	defaultLanguage := epubLanguage
+	if b.MetaData.Language != "" {
+		defaultLanguage = b.MetaData.Language
+	}
+
+	language := config.Option("epub", "language", defaultLanguage)
+
+	staticFiles, err := getStaticFiles(config.StaticDir)
+	if err != nil {
+		return err
+	}
+
+	var stylesheets []string
+	for _, staticFile := range staticFiles {
+		if path.Ext(staticFile) == ".css" {
+			stylesheets = append(stylesheets, staticFile)
+		}
+	}
+
+	var outputFile bytes.Buffer
+
+	buildTime, err := getBuildTime()
+	if err != nil {
+		return err
+	}
+
+	writer := &epubWriter{Writer: zip.NewWriter(&outputFile), modified: buildTime}
+
+	// The "mimetype" file must be the first file in the container and must not be compressed.
+	// See: https://www.w3.org/TR/epub-33/#sec-zip-container-mime
+	err = writeEpubFile(writer, "mimetype", []byte("application/epub+zip"), zip.Store)
+	if err != nil {
+		return err
+	}
+
+	err = writeEpubFile(writer, "META-INF/container.xml", []byte(epubContainer), zip.Deflate)
+	if err != nil {
+		return err
+	}
+
+	packageData := &epubPackageData{
+		Identifier: getEpubIdentifier(b.MetaData),
+		Language:   language,
+		Date:       getEpubDate(b.MetaData),
+		Modified:   getEpubModified(b.MetaData, buildTime),
+		MetaData:   b.MetaData,
+		Subjects:   getEpubSubjects(b.MetaData),
+	}
+
+	// Links to pages and to the title page point to their content documents, see getEpubDocument.
+	pagePaths := map[string]bool{"index.html": true}
+	for _, page := range b.Pages {
+		pagePaths[page.Path] = true
+	}
+
+	// navigation document
+	contents, err := i18n.New(language, config.Translations).Translate("contents")
+	if err != nil {
+		return err
+	}
+
+	nav, err := getEpubNav(b.Pages, contents)
+	if err != nil {
+		return err
+	}
+
+	err = writeEpubDocument(writer, "nav.xhtml", &epubDocumentData{
+		Language:    language,
+		Title:       b.MetaData.Title,
+		Stylesheets: stylesheets,
+		Body:        nav,
+	})
+	if err != nil {
+		return err
+	}
+
+	properties, err := getEpubProperties(nav, "nav")
+	if err != nil {
+		return err
+	}
+
+	packageData.Items = append(packageData.Items, &epubItem{Id: "nav", Href: "nav.xhtml", MediaType: "application/xhtml+xml", Properties: properties})
+
+	// title page with the preface
+	preface, err := getEpubXhtml(b.MetaData.Preface, "index.html", pagePaths)
+	if err != nil {
+		return err
+	}
+
+	var titlePage strings.Builder
+	titlePage.WriteString("<section epub:type=\"titlepage\">\n<h1>")
+	titlePage.WriteString(xmlEscape(b.MetaData.Title))
+	titlePage.WriteString("</h1>\n")
+	if b.MetaData.Author != "" {
+		titlePage.WriteString("<p class=\"author\">" + xmlEscape(b.MetaData.Author) + "</p>\n")
+	}
+	if b.MetaData.Date != nil {
+		titlePage.WriteString("<p class=\"date\">" + xmlEscape(b.MetaData.Date.Text) + "</p>\n")
+	}
+	titlePage.WriteString(preface)
+	titlePage.WriteString("\n</section>")
+
+	err = writeEpubDocument(writer, "index.xhtml", &epubDocumentData{
+		Language:    language,
+		Title:       b.MetaData.Title,
+		Type:        "frontmatter",
+		Stylesheets: stylesheets,
+		Body:        titlePage.String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	properties, err = getEpubProperties(titlePage.String())
+	if err != nil {
+		return err
+	}
+
+	packageData.Items = append(packageData.Items, &epubItem{Id: "index", Href: "index.xhtml", MediaType: "application/xhtml+xml", Properties: properties})
+	packageData.Spine = append(packageData.Spine, "index")
+
+	// content documents
+	for _, page := range b.Pages {
+		id := fmt.Sprintf("page-%d", page.Id)
+
+		document := getEpubDocument(page.Path)
+
+		title, err := getXhtml(page.Title.Html)
+		if err != nil {
+			return err
+		}
+
+		content, err := getEpubXhtml(page.Content.Html, page.Path, pagePaths)
+		if err != nil {
+			return err
+		}
+
+		heading := title
+		if page.Title.Prefix != "" {
+			heading = xmlEscape(page.Title.Prefix) + " " + title
+		}
+
+		body := fmt.Sprintf("<section>\n<h%d>%s</h%d>\n%s\n</section>", page.Level, heading, page.Level, content)
+
+		// Stylesheets are linked relative to the content document, e.g. in the nested layout.
+		var pageStylesheets []string
+		for _, stylesheet := range stylesheets {
+			pageStylesheets = append(pageStylesheets, book.RelativeURL(page.Path, stylesheet, false))
+		}
+
+		err = writeEpubDocument(writer, document, &epubDocumentData{
+			Language:    language,
+			Title:       page.Title.Text,
+			Type:        "chapter",
+			Stylesheets: pageStylesheets,
+			Body:        body,
+		})
+		if err != nil {
+			return err
+		}
+
+		properties, err := getEpubProperties(body)
+		if err != nil {
+			return err
+		}
+
+		packageData.Items = append(packageData.Items, &epubItem{Id: id, Href: document, MediaType: "application/xhtml+xml", Properties: properties})
+		packageData.Spine = append(packageData.Spine, id)
+	}
+
+	// static files
+	for index, staticFile := range staticFiles {
+		file, err := os.ReadFile(filepath.Join(config.StaticDir, filepath.FromSlash(staticFile)))
+		if err != nil {
+			return err
+		}
+
+		err = writeEpubFile(writer, path.Join(epubRoot, staticFile), file, zip.Deflate)
+		if err != nil {
+			return err
+		}
+
+		item := &epubItem{
+			Id:        fmt.Sprintf("static-%d", index+1),
+			Href:      staticFile,
+			MediaType: getMediaType(staticFile),
+		}
+
+		if staticFile == path.Clean(strings.TrimPrefix(b.MetaData.Cover, "/")) {
+			item.Properties = "cover-image"
+		}
+
+		packageData.Items = append(packageData.Items, item)
+	}
+
+	// package document
+	var packageDocument bytes.Buffer
+	err = epubTemplates.ExecuteTemplate(&packageDocument, "package", packageData)
+	if err != nil {
+		return err
+	}
+
+	err = writeEpubFile(writer, path.Join(epubRoot, "package.opf"), packageDocument.Bytes(), zip.Deflate)
+	if err != nil {
+		return err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	return config.writeFile(config.Option("epub", "file", epubFileName), func(writer io.Writer) error {
+		_, err := writer.Write(outputFile.Bytes())
+		return err
+	})
+}
+
+// getEpubNav returns the body of the navigation document, which is a nested list
+// of links following the page hierarchy below the given title, e.g. "Contents".
+func getEpubNav(pages []*book.Page, title string) (string, error) {
+	var stringBuilder strings.Builder
+	var levels []int // levels of the currently opened lists
+
+	stringBuilder.WriteString("<nav epub:type=\"toc\" id=\"toc\">\n<h1>" + xmlEscape(title) + "</h1>\n")
+
+	for _, page := range pages {
+		title, err := getXhtml(page.Title.Html)
+		if err != nil {
+			return "", err
+		}
+
+		if page.Title.Prefix != "" {
+			title = xmlEscape(page.Title.Prefix) + " " + title
+		}
+
+		current := len(levels) - 1
+
+		switch {
+		case len(levels) == 0 || page.Level > levels[current]:
+			// A deeper page opens a new list within the list item of its parent page.
+			stringBuilder.WriteString("<ol>\n")
+			levels = append(levels, page.Level)
+		default:
+			stringBuilder.WriteString("</li>\n")
+
+			// Close all lists whose parent page is on the same or a deeper level.
+			for len(levels) > 1 && page.Level <= levels[len(levels)-2] {
+				levels = levels[:len(levels)-1]
+				stringBuilder.WriteString("</ol>\n</li>\n")
+			}
+
+			// Skipped heading levels, e.g. h1 followed by h3 and h2, are siblings.
+			if page.Level < levels[len(levels)-1] {
+				levels[len(levels)-1] = page.Level
+			}
+		}
+
+		stringBuilder.WriteString(fmt.Sprintf("<li><a href=\"%s\">%s</a>", xmlEscape(getEpubDocument(page.Path)), title))
+	}
+
+	for range levels {
+		stringBuilder.WriteString("</li>\n</ol>\n")
+	}
+
+	stringBuilder.WriteString("</nav>")
+
+	return stringBuilder.String(), nil
+}
+
+// getEpubProperties returns the manifest properties of a content document with the given body
+// after the given properties, e.g. "nav mathml", as EPUB requires them for inline MathML and SVG
+// and for scripts and forms.
+// See: https://www.w3.org/TR/epub-33/#app-item-properties-vocab
+func getEpubProperties(body string, properties ...string) (string, error) {
+	tree, err := parsetree.New(body)
+	if err != nil {
+		return "", err
+	}
+
+	var mathml, svg, scripted bool
+
+	var visit func(node *html.Node)
+	visit = func(node *html.Node) {
+		if parsetree.IsElement(node) {
+			mathml = mathml || node.Namespace == "math"
+			svg = svg || node.Namespace == "svg"
+			scripted = scripted || node.Data == "script" || node.Data == "form"
+
+			// event handler attributes, e.g. "onclick"
+			for _, attribute := range node.Attr {
+				scripted = scripted || (attribute.Namespace == "" && strings.HasPrefix(attribute.Key, "on"))
+			}
+		}
+
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+	}
+
+	visit(tree)
+
+	if mathml {
+		properties = append(properties, "mathml")
+	}
+	if scripted {
+		properties = append(properties, "scripted")
+	}
+	if svg {
+		properties = append(properties, "svg")
+	}
+
+	return strings.Join(properties, " "), nil
+}
+
+// getEpubIdentifier returns the ISBN or identifier of the meta data, or else a stable, name-based
+// UUID for the book, so that a rebuild of the same book is recognized as the same publication by
+// e-book readers.
+func getEpubIdentifier(metaData *book.MetaData) string {
+	if metaData.ISBN != "" {
+		return "urn:isbn:" + metaData.ISBN
+	}
+
+	if metaData.Identifier != "" {
+		return metaData.Identifier
+	}
+
+	var date string
+	if metaData.Date != nil {
+		date = metaData.Date.Text
+	}
+
+	hash := sha1.Sum([]byte(metaData.Title + "\x00" + metaData.Author + "\x00" + date))
+
+	hash[6] = (hash[6] & 0x0f) | 0x50 // version 5
+	hash[8] = (hash[8] & 0x3f) | 0x80 // variant RFC 4122
+
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", hash[0:4], hash[4:6], hash[6:8], hash[8:10], hash[10:16])
+}
+
+// getEpubDate returns the publication date, or else the date of the meta data in ISO 8601
+// format, or an empty string, if neither is set.
+func getEpubDate(metaData *book.MetaData) string {
+	for _, date := range []*book.Date{metaData.Published, metaData.Date} {
+		if date != nil {
+			return date.ISO()
+		}
+	}
+
+	return ""
+}
+
+// getEpubModified returns the modification date of the meta data, or else the build time, in the
+// format required by EPUB, e.g. "2023-06-01T12:00:00Z".
+func getEpubModified(metaData *book.MetaData, buildTime time.Time) string {
+	modified := buildTime
+	if metaData.Modified != nil && metaData.Modified.IsValid() {
+		modified = metaData.Modified.Time
+	}
+
+	return modified.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+// getEpubSubjects returns the subjects and keywords of the meta data without duplicates, as EPUB
+// has no keywords.
+func getEpubSubjects(metaData *book.MetaData) []string {
+	var subjects []string
+
+	for _, subject := range append(append([]string{}, metaData.Subjects...), metaData.Keywords...) {
+		if !slices.Contains(subjects, subject) {
+			subjects = append(subjects, subject)
+		}
+	}
+
+	return subjects
+}
+
+// getStaticFiles returns the slash-separated paths of all regular files in the static
+// directory, relative to the static directory.
+func getStaticFiles(staticDir string) ([]string, error) {
+	var staticFiles []string
+
+	if staticDir == "" {
+		return staticFiles, nil
+	}
+
+	err := filepath.WalkDir(staticDir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		relativeName, err := filepath.Rel(staticDir, name)
+		if err != nil {
+			return err
+		}
+
+		staticFiles = append(staticFiles, filepath.ToSlash(relativeName))
+
+		return nil
+	})
+
+	return staticFiles, err
+}
+
+func getMediaType(name string) string {
+	mediaType := mime.TypeByExtension(path.Ext(name))
+	if mediaType == "" {
+		return "application/octet-stream"
+	}
+
+	// strip parameters like "charset=utf-8"
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+
+	return mediaType
+}
+
+// getEpubDocument returns the name of the content document of the page with the given path,
+// e.g. "1-intro/index.xhtml" for "1-intro/index.html".
+func getEpubDocument(pagePath string) string {
+	return strings.TrimSuffix(pagePath, path.Ext(pagePath)) + ".xhtml"
+}
+
+func getXhtml(fragment template.HTML) (string, error) {
+	return getEpubXhtml(fragment, "", nil)
+}
+
+// getEpubXhtml returns the fragment of the page with the given path as XHTML, whose relative
+// links to the given page paths point to their content documents, e.g. "../2-setup/index.xhtml#run"
+// instead of "../2-setup/index.html#run".
+func getEpubXhtml(fragment template.HTML, pagePath string, pagePaths map[string]bool) (string, error) {
+	tree, err := parsetree.New(string(fragment))
+	if err != nil {
+		return "", err
+	}
+
+	body := parsetree.Body(tree)
+
+	book.RewriteURLs(body, func(_ *html.Node, href string) string {
+		reference, err := url.Parse(href)
+		if err != nil || reference.Scheme != "" || reference.Host != "" || strings.HasPrefix(href, "/") {
+			return href
+		}
+
+		target, suffix := href, ""
+		if index := strings.IndexAny(href, "?#"); index >= 0 {
+			target, suffix = href[:index], href[index:]
+		}
+
+		if target == "" || !pagePaths[path.Join(path.Dir(pagePath), target)] {
+			return href
+		}
+
+		return getEpubDocument(target) + suffix
+	})
+
+	return parsetree.Xhtml(parsetree.Children(body)...)
+}
+
+func writeEpubDocument(writer *epubWriter, name string, data *epubDocumentData) error {
+	var document bytes.Buffer
+
+	err := epubTemplates.ExecuteTemplate(&document, "document", data)
+	if err != nil {
+		return err
+	}
+
+	return writeEpubFile(writer, path.Join(epubRoot, name), document.Bytes(), zip.Deflate)
+}
+
+func writeEpubFile(writer *epubWriter, name string, file []byte, method uint16) error {
+	header := &zip.FileHeader{
+		Name:   name,
+		Method: method,
+	}
+
+	// A modification time is stored in an extra field, which is not allowed for the "mimetype" file.
+	if name != "mimetype" {
+		header.Modified = writer.modified
+	}
+
+	fileWriter, err := writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = fileWriter.Write(file)
+
+	return err
+}
+
+// xmlEscape escapes the special characters of the given text. The numeric character
+// references used for quotes are valid in XML as well as in HTML.
+func xmlEscape(text string) string {
+	return html.EscapeString(text)
+}
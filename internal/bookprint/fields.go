@@ -0,0 +1,270 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package bookprint
+
+import (
+	"fmt"
+	"html/template"
+	"reflect"
+	"text/template/parse"
+)
+
+// fieldChecker resolves the field accesses of templates against the types of their data without
+// executing them, so that every unknown field is found, also in branches, which the sample books
+// never execute. Types, which are only known at execution time, e.g. the results of functions
+// returning "any", are not checked.
+type fieldChecker struct {
+	templates *template.Template
+	funcs     template.FuncMap
+	checked   map[string]bool // key: name of a template and type of its data
+	errors    []error
+}
+
+// fieldScope is the data of a node: the type of dot and the types of the variables, including
+// "$" for the data of the template. A nil type is unknown.
+type fieldScope struct {
+	tree      *parse.Tree
+	dot       reflect.Type
+	variables map[string]reflect.Type
+}
+
+// checkFields returns an error for every unknown field accessed by the template with the given
+// name and by the templates it calls, with the data of the given type. The errors have the
+// format of execution errors, e.g. "template: page.html:12:9: executing "page.html" at
+// <.Page.Title.Prefx>: can't evaluate field Prefx in type *book.Title".
+func checkFields(templates *template.Template, name string, data reflect.Type, funcs template.FuncMap) []error {
+	checker := &fieldChecker{templates: templates, funcs: funcs, checked: make(map[string]bool)}
+	checker.checkTemplate(name, data)
+
+	return checker.errors
+}
+
+func (c *fieldChecker) checkTemplate(name string, data reflect.Type) {
+	key := fmt.Sprintf("%s\x00%v", name, data)
+	if c.checked[key] {
+		return
+	}
+
+	c.checked[key] = true
+
+	t := c.templates.Lookup(name)
+	if t == nil || t.Tree == nil || t.Tree.Root == nil {
+		return // reported by the execution
+	}
+
+	c.checkNode(t.Tree.Root, &fieldScope{tree: t.Tree, dot: data, variables: map[string]reflect.Type{"$": data}})
+}
+
+func (c *fieldChecker) checkNode(node parse.Node, scope *fieldScope) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+
+		// Variables declared within a list are visible until its end.
+		inner := scope.copy(scope.dot)
+		for _, child := range node.Nodes {
+			c.checkNode(child, inner)
+		}
+	case *parse.ActionNode:
+		c.checkPipe(node.Pipe, scope)
+	case *parse.IfNode:
+		c.checkPipe(node.Pipe, scope)
+		c.checkNode(node.List, scope)
+		c.checkNode(node.ElseList, scope)
+	case *parse.WithNode:
+		inner := scope.copy(scope.dot)
+		inner.dot = c.checkPipe(node.Pipe, inner)
+		c.checkNode(node.List, inner)
+		c.checkNode(node.ElseList, scope)
+	case *parse.RangeNode:
+		inner := scope.copy(scope.dot)
+		key, element := getRangeTypes(c.checkPipeValue(node.Pipe, inner))
+
+		switch len(node.Pipe.Decl) {
+		case 1:
+			inner.variables[node.Pipe.Decl[0].Ident[0]] = element
+		case 2:
+			inner.variables[node.Pipe.Decl[0].Ident[0]] = key
+			inner.variables[node.Pipe.Decl[1].Ident[0]] = element
+		}
+
+		inner.dot = element
+		c.checkNode(node.List, inner)
+		c.checkNode(node.ElseList, scope)
+	case *parse.TemplateNode:
+		// Templates called without data, e.g. {{template "footer"}}, have no fields to check.
+		if node.Pipe != nil {
+			if data := c.checkPipe(node.Pipe, scope); data != nil {
+				c.checkTemplate(node.Name, data)
+			}
+		}
+	}
+}
+
+// checkPipe checks the commands of a pipeline, declares its variables and returns the type of its
+// result.
+func (c *fieldChecker) checkPipe(pipe *parse.PipeNode, scope *fieldScope) reflect.Type {
+	result := c.checkPipeValue(pipe, scope)
+
+	for _, variable := range pipe.Decl {
+		scope.variables[variable.Ident[0]] = result
+	}
+
+	return result
+}
+
+// checkPipeValue checks the commands of a pipeline without declaring its variables and returns
+// the type of its result.
+func (c *fieldChecker) checkPipeValue(pipe *parse.PipeNode, scope *fieldScope) reflect.Type {
+	if pipe == nil {
+		return nil
+	}
+
+	var result reflect.Type
+	for _, command := range pipe.Cmds {
+		result = c.checkCommand(command, scope)
+	}
+
+	return result
+}
+
+// checkCommand checks the arguments of a command and returns the type of its result.
+func (c *fieldChecker) checkCommand(command *parse.CommandNode, scope *fieldScope) reflect.Type {
+	var result reflect.Type
+
+	for index, argument := range command.Args {
+		argumentType := c.checkArgument(argument, scope)
+		if index == 0 {
+			result = argumentType
+		}
+	}
+
+	return result
+}
+
+// checkArgument checks an argument of a command and returns its type.
+func (c *fieldChecker) checkArgument(argument parse.Node, scope *fieldScope) reflect.Type {
+	switch argument := argument.(type) {
+	case *parse.DotNode:
+		return scope.dot
+	case *parse.FieldNode:
+		return c.resolveFields(argument, scope.dot, argument.Ident, scope)
+	case *parse.VariableNode:
+		return c.resolveFields(argument, scope.variables[argument.Ident[0]], argument.Ident[1:], scope)
+	case *parse.ChainNode:
+		return c.resolveFields(argument, c.checkArgument(argument.Node, scope), argument.Field, scope)
+	case *parse.PipeNode:
+		return c.checkPipeValue(argument, scope.copy(scope.dot))
+	case *parse.IdentifierNode:
+		return c.getFunctionType(argument.Ident)
+	}
+
+	return nil // constants are not checked
+}
+
+// resolveFields returns the type of the fields or methods accessed on a value of the given type,
+// e.g. "*book.Title" for the fields "Page" and "Title" on "*bookprint.Page".
+func (c *fieldChecker) resolveFields(node parse.Node, receiver reflect.Type, fields []string, scope *fieldScope) reflect.Type {
+	for _, field := range fields {
+		if receiver == nil || receiver.Kind() == reflect.Interface {
+			return nil
+		}
+
+		// Methods with pointer receivers are called on addressable values, too.
+		methods := receiver
+		if methods.Kind() != reflect.Pointer {
+			methods = reflect.PointerTo(receiver)
+		}
+
+		if method, ok := methods.MethodByName(field); ok {
+			receiver = getResultType(method.Type)
+			continue
+		}
+
+		structType := receiver
+		if structType.Kind() == reflect.Pointer {
+			structType = structType.Elem()
+		}
+
+		switch structType.Kind() {
+		case reflect.Struct:
+			if structField, ok := structType.FieldByName(field); ok && structField.IsExported() {
+				receiver = structField.Type
+				continue
+			}
+		case reflect.Map:
+			receiver = structType.Elem() // keys are checked by "missingkey=error" at execution
+			continue
+		}
+
+		location, context := scope.tree.ErrorContext(node)
+		c.errors = append(c.errors, fmt.Errorf("template: %s: executing %q at <%s>: can't evaluate field %s in type %s", location, scope.tree.Name, context, field, receiver))
+
+		return nil
+	}
+
+	return receiver
+}
+
+// getFunctionType returns the type of the result of a function of the templates, or nil for
+// functions with results of unknown type, e.g. "index".
+func (c *fieldChecker) getFunctionType(name string) reflect.Type {
+	function, ok := c.funcs[name]
+	if !ok || function == nil {
+		return nil
+	}
+
+	return getResultType(reflect.TypeOf(function))
+}
+
+// copy returns a scope with the given dot and a copy of the variables, so that variables declared
+// within a control structure are not visible after it.
+func (s *fieldScope) copy(dot reflect.Type) *fieldScope {
+	variables := make(map[string]reflect.Type, len(s.variables))
+	for name, variable := range s.variables {
+		variables[name] = variable
+	}
+
+	return &fieldScope{tree: s.tree, dot: dot, variables: variables}
+}
+
+// getResultType returns the type of the first result of a function or method, or nil, if it has
+// no result or returns an interface, e.g. "any".
+func getResultType(function reflect.Type) reflect.Type {
+	if function.Kind() != reflect.Func || function.NumOut() == 0 || function.Out(0).Kind() == reflect.Interface {
+		return nil
+	}
+
+	return function.Out(0)
+}
+
+// getRangeTypes returns the types of the keys and the elements of a range over a value of the
+// given type, or nil for unknown types.
+func getRangeTypes(value reflect.Type) (reflect.Type, reflect.Type) {
+	if value == nil {
+		return nil, nil
+	}
+
+	if value.Kind() == reflect.Pointer {
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		return reflect.TypeOf(0), value.Elem()
+	case reflect.Map:
+		return value.Key(), value.Elem()
+	case reflect.Chan:
+		return value.Elem(), value.Elem()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value, value
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,314 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package bookprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"stefanco.de/bookprint/internal/book"
+	"stefanco.de/bookprint/internal/i18n"
+	"stefanco.de/bookprint/internal/markdown"
+	"stefanco.de/bookprint/internal/theme"
+	"stefanco.de/bookprint/internal/util/parsetree"
+	"stefanco.de/bookprint/internal/util/slug"
+)
+
+// getFuncMap returns the functions available in the templates. Functions, which can fail,
+// return an error, which the template engine reports with the template, line and call.
+func getFuncMap(b *book.Book, config *Config) template.FuncMap {
+	pretty := config.Layout == book.NestedLayout
+	assets := make(map[string]string) // key: path of the asset, value: fingerprinted path
+	translator := i18n.New(b.MetaData.Language, config.Translations)
+
+	return template.FuncMap{
+		// relURL returns the URL of a page or asset relative to the current page,
+		// e.g. {{relURL .Path "style.css"}} or {{relURL .Path .Page.Next.Path}}.
+		"relURL": func(from string, to string) string {
+			return book.RelativeURL(from, to, pretty)
+		},
+		// pageByTitle returns the page with the given title text, e.g. {{(pageByTitle "Setup").Path}}.
+		"pageByTitle": func(title string) (*book.Page, error) {
+			return getPageByTitle(b, title)
+		},
+		// pageByID returns the page with the given id attribute of its heading, or with the
+		// given page id, e.g. {{(pageByID "setup").Path}} or {{(pageByID 3).Path}}.
+		"pageByID": func(id any) (*book.Page, error) {
+			return getPageById(b, id)
+		},
+		// asset returns the fingerprinted path of a file of the static directory or the default
+		// theme, e.g. "style.1a2b3c4d.css" for {{asset "style.css"}}, which is created on first use.
+		"asset": func(name string) (string, error) {
+			if fingerprinted, ok := assets[name]; ok {
+				return fingerprinted, nil
+			}
+
+			fingerprinted, err := createAsset(name, config)
+			if err != nil {
+				return "", err
+			}
+
+			assets[name] = fingerprinted
+
+			return fingerprinted, nil
+		},
+		// dateFormat formats a date with month and weekday names in the language of the book,
+		// e.g. "1. Juni 2023" for {{dateFormat "2. January 2006" .MetaData.Date}} and "de".
+		"dateFormat": func(layout string, date any) (string, error) {
+			return formatDate(layout, date, b.MetaData.Language)
+		},
+		// T returns the text of a key in the language of the book, formatted with the arguments,
+		// e.g. {{T "next"}} or {{T "translated-by" .Name}}.
+		"T": translator.Translate,
+		// now returns the build time, which honors SOURCE_DATE_EPOCH, e.g. {{dateFormat "2006" now}}.
+		"now":           getBuildTime,
+		"slugify":       slug.Make,
+		"truncateWords": truncateWords,
+		"plainText":     plainText,
+		"wordCount":     wordCount,
+		"markdownify":   markdownify,
+		"seq":           seq,
+		"dict":          dict,
+		"default":       defaultValue,
+	}
+}
+
+func getPageByTitle(b *book.Book, title string) (*book.Page, error) {
+	for _, page := range b.Pages {
+		if page.Title.Text == title {
+			return page, nil
+		}
+	}
+
+	return nil, fmt.Errorf("page with title '%s' does not exist", title)
+}
+
+func getPageById(b *book.Book, id any) (*book.Page, error) {
+	var matches func(page *book.Page) bool
+
+	switch value := id.(type) {
+	case int:
+		matches = func(page *book.Page) bool { return page.Id == value }
+	case string:
+		matches = func(page *book.Page) bool { return page.Anchor == value }
+	default:
+		return nil, fmt.Errorf("id must be a string or an integer, not '%T'", id)
+	}
+
+	for _, page := range b.Pages {
+		if matches(page) {
+			return page, nil
+		}
+	}
+
+	return nil, fmt.Errorf("page with id '%v' does not exist", id)
+}
+
+// createAsset copies a file of the static directory, or else of the default theme, to a file name
+// with the first 8 hexadecimal digits of its SHA-256 hash into the output directory, so that it
+// can be cached forever. It returns the path of the new file.
+func createAsset(name string, config *Config) (string, error) {
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", fmt.Errorf("asset '%s' is outside of the static directory", name)
+	}
+
+	var content []byte
+	var err error = fs.ErrNotExist
+
+	if config.StaticDir != "" {
+		content, err = os.ReadFile(filepath.Join(config.StaticDir, filepath.FromSlash(name)))
+	}
+	if errors.Is(err, fs.ErrNotExist) && config.TemplateDir == "" {
+		content, err = fs.ReadFile(theme.Static, name)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("asset '%s' does not exist", name)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(content)
+	extension := path.Ext(name)
+	fingerprinted := strings.TrimSuffix(name, extension) + "." + hex.EncodeToString(hash[:4]) + extension
+
+	err = config.writeFile(fingerprinted, func(writer io.Writer) error {
+		_, err := writer.Write(content)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fingerprinted, nil
+}
+
+// formatDate formats a date of the meta data, a date string or a time with a Go time layout in
+// the given language. An empty date results in an empty text.
+func formatDate(layout string, date any, language string) (string, error) {
+	switch value := date.(type) {
+	case *book.Date:
+		if value == nil {
+			return "", nil
+		}
+
+		if !value.IsValid() {
+			return "", fmt.Errorf("date '%s' has an unsupported format, e.g. use '2006-01-02'", value.Text)
+		}
+
+		return value.Format(layout, language), nil
+	case string:
+		parsed := book.ParseDate(value)
+		if parsed == nil {
+			return "", nil
+		}
+
+		return formatDate(layout, parsed, language)
+	case time.Time:
+		return book.FormatTime(value, layout, language), nil
+	default:
+		return "", fmt.Errorf("date must be a date, a string or a time, not '%T'", date)
+	}
+}
+
+// truncateWords returns the first words of a text or HTML as plain text, followed by an
+// ellipsis, if it was truncated, e.g. {{truncateWords 30 .Page.Content.Html}}.
+func truncateWords(count int, text any) (string, error) {
+	plain, err := plainText(text)
+	if err != nil {
+		return "", err
+	}
+
+	words := strings.Fields(plain)
+	if len(words) <= count {
+		return strings.Join(words, " "), nil
+	}
+
+	return strings.Join(words[:count], " ") + " …", nil
+}
+
+// plainText returns the text of an HTML fragment without tags and with whitespace collapsed,
+// e.g. {{plainText .Page.Title.Html}}. Strings are treated as plain text.
+func plainText(text any) (string, error) {
+	switch value := text.(type) {
+	case template.HTML:
+		tree, err := parsetree.New(string(value))
+		if err != nil {
+			return "", err
+		}
+
+		return strings.Join(strings.Fields(parsetree.Text(parsetree.Body(tree))), " "), nil
+	case string:
+		return strings.Join(strings.Fields(value), " "), nil
+	default:
+		return "", fmt.Errorf("text must be a string or HTML, not '%T'", text)
+	}
+}
+
+// wordCount returns the number of words of a text or HTML, e.g. {{wordCount .Page.Content.Html}}.
+func wordCount(text any) (int, error) {
+	plain, err := plainText(text)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(strings.Fields(plain)), nil
+}
+
+// markdownify converts Markdown to HTML, e.g. {{markdownify .MetaData.Title}}. The paragraph
+// around a single line is removed, so that the result can be used inline.
+func markdownify(text string) template.HTML {
+	converted := strings.TrimSpace(string(markdown.Html([]byte(text))))
+
+	if strings.HasPrefix(converted, "<p>") && strings.HasSuffix(converted, "</p>") && strings.Count(converted, "<p>") == 1 {
+		converted = strings.TrimSuffix(strings.TrimPrefix(converted, "<p>"), "</p>")
+	}
+
+	return template.HTML(converted)
+}
+
+// seq returns the numbers from 1 to the last number, or from the first to the last number,
+// e.g. {{range seq 3}} for 1, 2, 3 or {{range seq 0 2}} for 0, 1, 2.
+func seq(numbers ...int) ([]int, error) {
+	first, last := 1, 0
+
+	switch len(numbers) {
+	case 1:
+		last = numbers[0]
+	case 2:
+		first, last = numbers[0], numbers[1]
+	default:
+		return nil, fmt.Errorf("seq expects 1 or 2 numbers, not %d", len(numbers))
+	}
+
+	var sequence []int
+	for number := first; number <= last; number++ {
+		sequence = append(sequence, number)
+	}
+
+	return sequence, nil
+}
+
+// dict returns a map of the given keys and values, e.g. to pass several values to a template:
+// {{template "link" dict "page" .Page "path" .Path}}.
+func dict(keysAndValues ...any) (map[string]any, error) {
+	if len(keysAndValues)%2 != 0 {
+		return nil, errors.New("dict expects pairs of keys and values")
+	}
+
+	values := make(map[string]any, len(keysAndValues)/2)
+
+	for index := 0; index < len(keysAndValues); index += 2 {
+		key, ok := keysAndValues[index].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict keys must be strings, not '%T'", keysAndValues[index])
+		}
+
+		values[key] = keysAndValues[index+1]
+	}
+
+	return values, nil
+}
+
+// defaultValue returns the value, or the default value, if the value is empty, i.e. nil, false,
+// 0 or an empty string, slice or map, e.g. {{.MetaData.Author | default "Anonymous"}}.
+func defaultValue(defaultValue any, value any) any {
+	if value == nil {
+		return defaultValue
+	}
+
+	reflected := reflect.ValueOf(value)
+
+	switch reflected.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		if reflected.Len() == 0 {
+			return defaultValue
+		}
+	case reflect.Pointer, reflect.Interface:
+		if reflected.IsNil() {
+			return defaultValue
+		}
+	default:
+		if reflected.IsZero() {
+			return defaultValue
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,327 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package bookprint
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"stefanco.de/bookprint/internal/book"
+	"stefanco.de/bookprint/internal/search"
+	"stefanco.de/bookprint/internal/theme"
+	utilFs "stefanco.de/bookprint/internal/util/fs"
+	"stefanco.de/bookprint/internal/util/parsetree"
+)
+
+// htmlRenderer renders the book as website from the templates in the template directory, or
+// of the default theme. Every template is rendered once with the whole book, e.g. "index.html",
+// "map.html" or "about/index.html", except for the page templates, which are rendered for every
+// page, see isPageTemplate.
+type htmlRenderer struct{}
+
+// Book is the data of the templates rendered once, e.g. "index.html" and "map.html".
+type Book struct {
+	*book.Book
+	Path         string              // path of the output file, e.g. "map.html"
+	SearchIndex  string              // path of the search index, empty if disabled
+	Translations []*book.Translation // output file in the other languages, see getTranslations
+}
+
+// Page is the data of the page templates, e.g. "page.html".
+type Page struct {
+	MetaData     *book.MetaData
+	Page         *book.Page
+	Path         string              // path of the output file, e.g. "print/1-intro.html" for "print/_page.html"
+	SearchIndex  string              // path of the search index, empty if disabled
+	Translations []*book.Translation // output file in the other languages, see getTranslations
+}
+
+func (r *htmlRenderer) Render(b *book.Book, config *Config) error {
+	set, err := parseTemplates(b, config)
+	if err != nil {
+		return err
+	}
+
+	if config.TemplateDir == "" {
+		err := copyThemeFiles(config)
+		if err != nil {
+			return err
+		}
+	}
+
+	searchIndex, err := createSearchIndex(b, config)
+	if err != nil {
+		return err
+	}
+
+	data := &Book{
+		Book:        b,
+		SearchIndex: searchIndex,
+	}
+
+	for _, name := range set.names {
+		t := set.templates[name]
+
+		if isPageTemplate(name) {
+			err = createPages(data, t, config)
+		} else {
+			err = createFile(data, t, config)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Check parses the templates and reports missing optional templates as notes.
+func (r *htmlRenderer) Check(b *book.Book, config *Config) error {
+	set, err := parseTemplates(b, config)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range set.missing {
+		config.note("optional template '%s' does not exist, skipping it", filepath.Join(config.TemplateDir, name))
+	}
+
+	return nil
+}
+
+// Options returns the supported options: "search" enables the search index, which is
+// enabled by default, and "inverted-index" adds an inverted index to the search index.
+func (r *htmlRenderer) Options() []string {
+	return []string{"search", "inverted-index"}
+}
+
+// createSearchIndex writes the search index of the book into the output directory
+// and returns its path, or an empty path if the search index is disabled.
+func createSearchIndex(b *book.Book, config *Config) (string, error) {
+	enabled, err := strconv.ParseBool(config.Option("html", "search", "true"))
+	if err != nil {
+		return "", fmt.Errorf("option 'search' must be true or false: %w", err)
+	}
+
+	if !enabled {
+		return "", nil
+	}
+
+	inverted, err := strconv.ParseBool(config.Option("html", "inverted-index", "false"))
+	if err != nil {
+		return "", fmt.Errorf("option 'inverted-index' must be true or false: %w", err)
+	}
+
+	index, err := search.New(b, inverted)
+	if err != nil {
+		return "", err
+	}
+
+	err = config.writeFile(search.FileName, func(writer io.Writer) error {
+		encoder := json.NewEncoder(writer)
+		encoder.SetEscapeHTML(false)
+
+		return encoder.Encode(index)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return search.FileName, nil
+}
+
+// createFile renders a template once with the whole book into the output file with the path
+// of the template, e.g. "about/index.html".
+func createFile(b *Book, t *template.Template, config *Config) error {
+	metaData, err := getOutputMetaData(b.MetaData, t.Name(), ".", b.Pages, config.Layout == book.NestedLayout)
+	if err != nil {
+		return err
+	}
+
+	outputBook := *b.Book
+	outputBook.MetaData = metaData
+
+	data := *b
+	data.Book = &outputBook
+	data.Path = t.Name()
+	data.Translations = getTranslations(b.Book.Translations, func(*book.Translation) string {
+		return t.Name()
+	})
+
+	return config.writeFile(data.Path, func(writer io.Writer) error {
+		return t.Execute(writer, &data)
+	})
+}
+
+// createPages renders a page template for every page into the output file with the path of the
+// page, within the directory of the template, e.g. "print/1-intro.html" for "print/_page.html".
+func createPages(b *Book, t *template.Template, config *Config) error {
+	var err error
+
+	for _, page := range b.Pages {
+		outputPath := path.Join(path.Dir(t.Name()), page.Path)
+
+		page, err = getOutputPage(page, path.Dir(t.Name()), b.Pages, config.Layout == book.NestedLayout)
+		if err != nil {
+			return err
+		}
+
+		metaData, err := getOutputMetaData(b.MetaData, outputPath, path.Dir(t.Name()), b.Pages, config.Layout == book.NestedLayout)
+		if err != nil {
+			return err
+		}
+
+		err = config.writeFile(outputPath, func(writer io.Writer) error {
+			return t.Execute(writer, &Page{
+				MetaData:    metaData,
+				Page:        page,
+				Path:        outputPath,
+				SearchIndex: b.SearchIndex,
+				Translations: getTranslations(page.Translations, func(translation *book.Translation) string {
+					return path.Join(path.Dir(t.Name()), translation.Path)
+				}),
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getTranslations returns the translations of a multilingual book with the paths of their output
+// files relative to the output directory of the current language, e.g. "../en/print/page1.html"
+// for the output path "print/page1.html".
+func getTranslations(translations []*book.Translation, outputPath func(translation *book.Translation) string) []*book.Translation {
+	var result []*book.Translation
+
+	for _, translation := range translations {
+		result = append(result, &book.Translation{
+			Language: translation.Language,
+			Title:    translation.Title,
+			Path:     path.Join("..", translation.Language, outputPath(translation)),
+		})
+	}
+
+	return result
+}
+
+// copyThemeFiles copies the static files of the default theme into the output directory,
+// except for files of the static directory with the same name, e.g. a custom "style.css".
+func copyThemeFiles(config *Config) error {
+	return fs.WalkDir(theme.Static, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		if config.StaticDir != "" && utilFs.ExistFile(filepath.Join(config.StaticDir, filepath.FromSlash(name))) {
+			return nil
+		}
+
+		content, err := fs.ReadFile(theme.Static, name)
+		if err != nil {
+			return err
+		}
+
+		return config.writeFile(name, func(writer io.Writer) error {
+			_, err := writer.Write(content)
+			return err
+		})
+	})
+}
+
+// getOutputPage returns a copy of the page, whose relative URLs in the content are relative to the
+// output file of the page instead of the path of the page, e.g. "../img/figure.png" for
+// "print/1-intro.html" of "print/_page.html". Links to pages refer to the pages rendered by the same
+// template. With pretty, links to "index.html" files become links to their directories, e.g.
+// "../setup/" instead of "../setup/index.html".
+func getOutputPage(page *book.Page, directory string, pages []*book.Page, pretty bool) (*book.Page, error) {
+	content, err := getOutputContent(page.Content.Html, page.Path, path.Join(directory, page.Path), directory, pages, pretty)
+	if err != nil || content == page.Content.Html {
+		return page, err
+	}
+
+	outputPage := *page
+	outputPage.Content = &book.Content{Html: content}
+
+	return &outputPage, nil
+}
+
+// getOutputMetaData returns a copy of the meta data, whose preface has the relative URLs of
+// getOutputPage for the output file with the given path, e.g. "../setup/" instead of
+// "setup/index.html" on "index.html" of the nested layout.
+func getOutputMetaData(metaData *book.MetaData, outputPath string, directory string, pages []*book.Page, pretty bool) (*book.MetaData, error) {
+	preface, err := getOutputContent(metaData.Preface, "index.html", outputPath, directory, pages, pretty)
+	if err != nil || preface == metaData.Preface {
+		return metaData, err
+	}
+
+	outputMetaData := *metaData
+	outputMetaData.Preface = preface
+
+	return &outputMetaData, nil
+}
+
+// getOutputContent returns the content of the file with the given path, whose relative URLs are
+// relative to the output file instead, see getOutputPage.
+func getOutputContent(content template.HTML, contentPath string, outputPath string, directory string, pages []*book.Page, pretty bool) (template.HTML, error) {
+	if content == "" || (outputPath == contentPath && directory == "." && !pretty) {
+		return content, nil
+	}
+
+	pagePaths := make(map[string]bool)
+	for _, p := range pages {
+		pagePaths[p.Path] = true
+
+		if path.Base(p.Path) == "index.html" {
+			pagePaths[path.Dir(p.Path)] = true // e.g. "setup/" for "setup/index.html"
+		}
+	}
+
+	tree, err := parsetree.New(string(content))
+	if err != nil {
+		return "", err
+	}
+
+	body := parsetree.Body(tree)
+
+	book.RewriteURLs(body, func(_ *html.Node, href string) string {
+		reference, err := url.Parse(href)
+		if err != nil || reference.Scheme != "" || reference.Host != "" || strings.HasPrefix(href, "/") || strings.HasPrefix(href, "#") {
+			return href
+		}
+
+		target, suffix := href, ""
+		if index := strings.IndexAny(href, "?#"); index >= 0 {
+			target, suffix = href[:index], href[index:]
+		}
+
+		// relative to the output directory, e.g. "img/figure.png" for "../img/figure.png" on "1-intro/index.html"
+		resolved := path.Join(path.Dir(contentPath), target)
+		if pagePaths[resolved] {
+			resolved = path.Join(directory, resolved)
+		}
+		if strings.HasSuffix(target, "/") {
+			resolved += "/"
+		}
+
+		return book.RelativeURL(outputPath, resolved+suffix, pretty)
+	})
+
+	return parsetree.Html(parsetree.Children(body)...)
+}
@@ -0,0 +1,34 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package bookprint
+
+import (
+	"encoding/json"
+	"io"
+
+	"stefanco.de/bookprint/internal/book"
+)
+
+const jsonFileName = "book.json"
+
+// jsonRenderer writes the book model as JSON file, e.g. for processing by other tools.
+type jsonRenderer struct{}
+
+func (r *jsonRenderer) Render(b *book.Book, config *Config) error {
+	return config.writeFile(config.Option("json", "file", jsonFileName), func(writer io.Writer) error {
+		encoder := json.NewEncoder(writer)
+		encoder.SetEscapeHTML(false) // page contents are HTML anyway
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(b)
+	})
+}
+
+// Options returns the supported options: "file" is the name of the JSON file.
+func (r *jsonRenderer) Options() []string {
+	return []string{"file"}
+}
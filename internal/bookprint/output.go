@@ -0,0 +1,53 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package bookprint
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// writeFile renders a file with the given path relative to the output directory, or to the
+// directory of the language of a multilingual book. Files with unchanged content are not
+// rewritten, so that rebuilds only touch affected files. Paths outside of the output directory,
+// e.g. "../index.html", are rejected.
+func (c *Config) writeFile(name string, render func(writer io.Writer) error) error {
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return fmt.Errorf("output file '%s' is outside of the output directory", name)
+	}
+
+	name = path.Join(c.languageDir, filepath.ToSlash(name))
+
+	var buffer bytes.Buffer
+
+	err := render(&buffer)
+	if err != nil {
+		return err
+	}
+
+	if c.Outputs != nil {
+		c.Outputs[name] = true
+	}
+
+	outputFileName := filepath.Join(c.OutputDir, filepath.FromSlash(name))
+
+	existing, err := os.ReadFile(outputFileName)
+	if err == nil && bytes.Equal(existing, buffer.Bytes()) {
+		return nil
+	}
+
+	err = os.MkdirAll(filepath.Dir(outputFileName), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(outputFileName, buffer.Bytes(), 0666)
+}
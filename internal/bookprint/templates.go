@@ -0,0 +1,277 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package bookprint
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"stefanco.de/bookprint/internal/book"
+	"stefanco.de/bookprint/internal/theme"
+	utilFs "stefanco.de/bookprint/internal/util/fs"
+	"stefanco.de/bookprint/internal/util/slices"
+)
+
+// sharedTemplateDirs are the subdirectories of the template directory, whose templates are
+// available in all other templates, e.g. {{template "partials/header.html" .}} or the
+// templates defined within, e.g. {{template "header" .}}.
+var sharedTemplateDirs = []string{"partials", "layouts"}
+
+// requiredTemplates are the templates, which every template directory must contain.
+var requiredTemplates = []string{"index.html", "page.html"}
+
+// optionalTemplates are the templates, which are skipped with a note, if they are missing.
+var optionalTemplates = []string{"map.html"}
+
+// pageTemplateName is the file name of additional page templates, e.g. "print/_page.html".
+const pageTemplateName = "_page.html"
+
+// templateExtensions are the file extensions of the templates in the template directory.
+// Other files are ignored.
+var templateExtensions = []string{".html", ".htm", ".xhtml"}
+
+// templateSet contains the parsed templates of the template directory.
+type templateSet struct {
+	names     []string                      // in alphabetical order
+	templates map[string]*template.Template // key: name
+	missing   []string                      // missing optional templates
+}
+
+// parseTemplates checks the template directory and parses all templates, see getTemplateNames.
+// Missing required templates, templates creating the same output file and syntax errors are
+// reported as errors, so that they are found before the output directory is touched.
+func parseTemplates(b *book.Book, config *Config) (*templateSet, error) {
+	if config.TemplateDir != "" && !utilFs.ExistDir(config.TemplateDir) {
+		return nil, fmt.Errorf("template directory '%s' does not exist", config.TemplateDir)
+	}
+
+	names, err := getTemplateNames(config)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range requiredTemplates {
+		if !slices.Contains(names, name) {
+			return nil, getMissingTemplateError(name, config)
+		}
+	}
+
+	set := &templateSet{
+		names:     names,
+		templates: make(map[string]*template.Template),
+	}
+
+	for _, name := range optionalTemplates {
+		if !slices.Contains(names, name) {
+			set.missing = append(set.missing, name)
+		}
+	}
+
+	err = checkOutputPaths(names, b.Pages)
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := parseSharedTemplates(b, config)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		set.templates[name], err = parseTemplate(name, shared, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return set, nil
+}
+
+// getMissingTemplateError returns the error for a missing required template, which lists the
+// files of the template directory, so that e.g. a misspelled file name can be spotted.
+func getMissingTemplateError(name string, config *Config) error {
+	var files []string
+
+	err := fs.WalkDir(getTemplateFS(config), ".", func(name string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			files = append(files, name)
+		}
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	contents := "which is empty"
+	if len(files) > 0 {
+		contents = "which contains: " + strings.Join(files, ", ")
+	}
+
+	return fmt.Errorf("required template '%s' does not exist in template directory '%s', %s. "+
+		"Create it, or run 'bookprint init' for a copy of the default templates", name, config.TemplateDir, contents)
+}
+
+// getTemplateFS returns the files of the template directory, or the templates of the default
+// theme, if there is no template directory.
+func getTemplateFS(config *Config) fs.FS {
+	if config.TemplateDir == "" {
+		return theme.Templates
+	}
+
+	return os.DirFS(config.TemplateDir)
+}
+
+// parseSharedTemplates parses all templates of the shared template directories into a set,
+// which is cloned for every template, so that each can override the blocks of a layout, e.g.
+// {{define "main"}}...{{end}} for {{block "main" .}}{{end}} in "layouts/base.html".
+func parseSharedTemplates(b *book.Book, config *Config) (*template.Template, error) {
+	templates := template.New("").Funcs(getFuncMap(b, config))
+	templateFS := getTemplateFS(config)
+
+	names, err := getSharedTemplateNames(config)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		content, err := fs.ReadFile(templateFS, name)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = templates.New(name).Parse(string(content))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return templates, nil
+}
+
+// getSharedTemplateNames returns the slash-separated paths of all files in the shared template
+// directories, e.g. "partials/header.html".
+func getSharedTemplateNames(config *Config) ([]string, error) {
+	var names []string
+
+	for _, directory := range sharedTemplateDirs {
+		err := fs.WalkDir(getTemplateFS(config), directory, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if name == directory && errors.Is(err, fs.ErrNotExist) {
+					return nil // shared templates are optional
+				}
+
+				return err
+			}
+
+			if !entry.IsDir() {
+				names = append(names, name)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return names, nil
+}
+
+// parseTemplate parses the template with the given file name into a copy of the shared templates.
+func parseTemplate(name string, templates *template.Template, config *Config) (*template.Template, error) {
+	content, err := fs.ReadFile(getTemplateFS(config), name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("template '%s' does not exist", filepath.Join(config.TemplateDir, name))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err = templates.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	return templates.New(name).Parse(string(content))
+}
+
+// getTemplateNames returns the slash-separated paths of all templates in the template directory,
+// except for the shared templates and hidden files, in alphabetical order.
+func getTemplateNames(config *Config) ([]string, error) {
+	var names []string
+
+	err := fs.WalkDir(getTemplateFS(config), ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if name != "." && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+
+			return nil
+		}
+
+		if entry.IsDir() {
+			if slices.Contains(sharedTemplateDirs, name) {
+				return fs.SkipDir
+			}
+
+			return nil
+		}
+
+		if slices.Contains(templateExtensions, strings.ToLower(path.Ext(name))) {
+			names = append(names, name)
+		}
+
+		return nil
+	})
+
+	return names, err
+}
+
+// isPageTemplate returns whether the template is rendered for every page, i.e. "page.html"
+// or a template named "_page.html", e.g. "print/_page.html".
+func isPageTemplate(name string) bool {
+	return name == "page.html" || path.Base(name) == pageTemplateName
+}
+
+// checkOutputPaths returns an error, if two templates create the same output file, e.g.
+// a page with the path "about.html" and the template "about.html".
+func checkOutputPaths(names []string, pages []*book.Page) error {
+	outputPaths := make(map[string]string) // key: output path, value: template name
+
+	for _, name := range names {
+		var paths []string
+
+		if isPageTemplate(name) {
+			for _, page := range pages {
+				paths = append(paths, path.Join(path.Dir(name), page.Path))
+			}
+		} else {
+			paths = append(paths, name)
+		}
+
+		for _, outputPath := range paths {
+			if other, ok := outputPaths[outputPath]; ok {
+				return fmt.Errorf("templates '%s' and '%s' both create the file '%s'", other, name, outputPath)
+			}
+
+			outputPaths[outputPath] = name
+		}
+	}
+
+	return nil
+}
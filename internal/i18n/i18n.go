@@ -0,0 +1,169 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+// Package i18n provides the translations of the texts of the templates, e.g. "Next", and the
+// writing direction of languages. The built-in catalogs are embedded into the binary.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"stefanco.de/bookprint/internal/util/slices"
+)
+
+// DefaultLanguage is the language of the texts without translation into the book language.
+const DefaultLanguage = "en"
+
+//go:embed catalogs
+var files embed.FS
+
+// catalogs are the built-in translations, key: language, value: texts by key.
+var catalogs = getCatalogs()
+
+// rightToLeftLanguages are the languages written from right to left, e.g. Arabic and Hebrew.
+var rightToLeftLanguages = []string{"ar", "arc", "ckb", "dv", "fa", "he", "ks", "ku", "ps", "sd", "syr", "ug", "ur", "yi"}
+
+// rightToLeftScripts are the scripts written from right to left, e.g. "Arab" for "az-Arab".
+var rightToLeftScripts = []string{"adlm", "arab", "hebr", "nkoo", "rohg", "syrc", "thaa"}
+
+// Translator translates the keys of the catalogs into a language.
+type Translator struct {
+	language string
+	catalogs []map[string]string // in order of precedence
+}
+
+// New returns a translator into the given language, which defaults to English. The overrides
+// take precedence over the built-in catalogs and may add keys, key: language, value: texts by key.
+// Keys without translation into the language fall back to the base language, e.g. "de" for "de-AT",
+// and to English.
+func New(language string, overrides map[string]map[string]string) *Translator {
+	language = strings.TrimSpace(language)
+	if language == "" {
+		language = DefaultLanguage
+	}
+
+	translator := &Translator{language: language}
+
+	var candidates []string
+	for _, candidate := range []string{language, BaseLanguage(language), DefaultLanguage} {
+		if !slices.Contains(candidates, candidate) {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	for _, candidate := range candidates {
+		for _, source := range []map[string]map[string]string{overrides, catalogs} {
+			if catalog, ok := source[candidate]; ok {
+				translator.catalogs = append(translator.catalogs, catalog)
+			}
+		}
+	}
+
+	return translator
+}
+
+// Translate returns the text of the key, formatted with the arguments, if any, e.g. "Übersetzt von
+// Jane Doe" for the key "translated-by" with the argument "Jane Doe" and the language "de".
+func (t *Translator) Translate(key string, arguments ...any) (string, error) {
+	for _, catalog := range t.catalogs {
+		if text, ok := catalog[key]; ok {
+			if len(arguments) > 0 {
+				text = fmt.Sprintf(text, arguments...)
+			}
+
+			return text, nil
+		}
+	}
+
+	return "", fmt.Errorf("key '%s' has no translation into language '%s', available keys are: %s", key, t.language, strings.Join(t.keys(), ", "))
+}
+
+// keys returns the sorted keys of all catalogs of the translator.
+func (t *Translator) keys() []string {
+	unique := make(map[string]bool)
+	for _, catalog := range t.catalogs {
+		for key := range catalog {
+			unique[key] = true
+		}
+	}
+
+	var keys []string
+	for key := range unique {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// BaseLanguage returns the language without script and region in lower case, e.g. "de" for "de-AT".
+func BaseLanguage(language string) string {
+	language, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(language)), "-")
+	language, _, _ = strings.Cut(language, "_")
+
+	return language
+}
+
+// Direction returns the writing direction of a language, i.e. "rtl" for languages written from
+// right to left, like "ar", "he" or "az-Arab", or else "ltr".
+func Direction(language string) string {
+	subtags := strings.FieldsFunc(strings.ToLower(language), func(r rune) bool { return r == '-' || r == '_' })
+	if len(subtags) == 0 {
+		return "ltr"
+	}
+
+	// An explicit script takes precedence, e.g. "ku-Latn" is written from left to right.
+	for _, subtag := range subtags[1:] {
+		if len(subtag) == 4 {
+			if slices.Contains(rightToLeftScripts, subtag) {
+				return "rtl"
+			}
+
+			return "ltr"
+		}
+	}
+
+	if slices.Contains(rightToLeftLanguages, subtags[0]) {
+		return "rtl"
+	}
+
+	return "ltr"
+}
+
+// getCatalogs parses the embedded catalogs, named by their language, e.g. "de.json".
+func getCatalogs() map[string]map[string]string {
+	result := make(map[string]map[string]string)
+
+	entries, err := fs.ReadDir(files, "catalogs")
+	if err != nil {
+		panic(err) // only fails for an invalid directory name
+	}
+
+	for _, entry := range entries {
+		content, err := files.ReadFile(path.Join("catalogs", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+
+		catalog := make(map[string]string)
+
+		err = json.Unmarshal(content, &catalog)
+		if err != nil {
+			panic(fmt.Errorf("invalid catalog '%s': %w", entry.Name(), err)) // embedded, so caught by every build
+		}
+
+		result[strings.TrimSuffix(entry.Name(), path.Ext(entry.Name()))] = catalog
+	}
+
+	return result
+}
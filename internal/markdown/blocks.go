@@ -0,0 +1,662 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package markdown
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type blockKind int
+
+const (
+	paragraphBlock blockKind = iota
+	headingBlock
+	codeBlock
+	htmlBlock
+	thematicBreakBlock
+	quoteBlock
+	listBlock
+	itemBlock
+	tableBlock
+)
+
+type block struct {
+	kind       blockKind
+	text       string      // inline source of paragraphs, headings and table cells, literal content of code and HTML
+	level      int         // heading level
+	info       string      // info string of fenced code blocks
+	attributes *attributes // heading attributes, e.g. {#id .class}
+	ordered    bool        // ordered list
+	start      int         // start number of ordered lists
+	tight      bool        // list without blank lines between its items
+	task       int         // task list item: 0 = none, 1 = unchecked, 2 = checked
+	children   []*block
+	header     []string   // table header cells
+	alignments []string   // table column alignments
+	rows       [][]string // table body cells
+}
+
+type attributes struct {
+	id      string
+	classes []string
+	keys    []string
+	values  map[string]string
+}
+
+type listMarker struct {
+	ordered   bool
+	bullet    byte // "-", "+" or "*" for bullet lists
+	delimiter byte // "." or ")" for ordered lists
+	start     int
+	indent    int  // column where the content of the list item starts
+	empty     bool // list item without content on its first line
+}
+
+var (
+	atxHeadingRegexp    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))??(?:[ \t]+#+)?[ \t]*$`)
+	setextHeadingRegexp = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	thematicBreakRegexp = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fenceRegexp         = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \\t]*(.*)$")
+	listMarkerRegexp    = regexp.MustCompile(`^( {0,3})([-+*]|\d{1,9}[.)])([ \t]+|$)`)
+	quoteRegexp         = regexp.MustCompile(`^ {0,3}> ?`)
+	tableDelimiterRow   = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	attributesRegexp    = regexp.MustCompile(`[ \t]*\{([^{}]*)\}[ \t]*$`)
+	referenceRegexp     = regexp.MustCompile(`^ {0,3}\[((?:[^\]\\]|\\.){1,999})\]:[ \t]*\n?[ \t]*(<[^<>\n]*>|[^<\s]\S*)(?:[ \t]*\n?[ \t]*("(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|\((?:[^()\\]|\\.)*\)))?[ \t]*(?:\n|$)`)
+)
+
+// htmlBlockStarts and htmlBlockEnds are the start and end conditions of the seven kinds of HTML blocks.
+// See: https://spec.commonmark.org/0.30/#html-blocks
+var htmlBlockStarts = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^ {0,3}<(?:script|pre|style|textarea)(?:\s|>|$)`),
+	regexp.MustCompile(`^ {0,3}<!--`),
+	regexp.MustCompile(`^ {0,3}<\?`),
+	regexp.MustCompile(`^ {0,3}<![A-Za-z]`),
+	regexp.MustCompile(`^ {0,3}<!\[CDATA\[`),
+	regexp.MustCompile(`(?i)^ {0,3}</?(?:address|article|aside|base|basefont|blockquote|body|caption|center|col|colgroup|dd|details|dialog|dir|div|dl|dt|fieldset|figcaption|figure|footer|form|frame|frameset|h1|h2|h3|h4|h5|h6|head|header|hr|html|iframe|legend|li|link|main|menu|menuitem|nav|noframes|ol|optgroup|option|p|param|section|source|summary|table|tbody|td|tfoot|th|thead|title|tr|track|ul)(?:\s|/?>|$)`),
+	regexp.MustCompile(`^ {0,3}(?:<[A-Za-z][A-Za-z0-9-]*(?:\s+[A-Za-z_:][A-Za-z0-9_.:-]*(?:\s*=\s*(?:[^\s"'=<>` + "`" + `]+|'[^']*'|"[^"]*"))?)*\s*/?>|</[A-Za-z][A-Za-z0-9-]*\s*>)[ \t]*$`),
+}
+
+var htmlBlockEnds = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)</(?:script|pre|style|textarea)>`),
+	regexp.MustCompile(`-->`),
+	regexp.MustCompile(`\?>`),
+	regexp.MustCompile(`>`),
+	regexp.MustCompile(`]]>`),
+	nil, // ends at a blank line
+	nil, // ends at a blank line
+}
+
+func (p *parser) parseBlocks(lines []string) []*block {
+	var blocks []*block
+
+	for index := 0; index < len(lines); {
+		line := lines[index]
+
+		if isBlank(line) {
+			index++
+			continue
+		}
+
+		// indented code block
+		if indentation(line) >= 4 {
+			var code []string
+
+			for ; index < len(lines) && (isBlank(lines[index]) || indentation(lines[index]) >= 4); index++ {
+				code = append(code, stripIndentation(lines[index], 4))
+			}
+
+			code = trimBlankLines(code)
+			blocks = append(blocks, &block{kind: codeBlock, text: strings.Join(code, "\n") + "\n"})
+
+			continue
+		}
+
+		// fenced code block
+		if matches := fenceRegexp.FindStringSubmatch(line); matches != nil && !(matches[2][0] == '`' && strings.Contains(matches[3], "`")) {
+			fenceIndentation := len(matches[1])
+			fence := matches[2]
+			info := unescapeString(strings.TrimSpace(matches[3]))
+
+			var code []string
+
+			for index++; index < len(lines); index++ {
+				closing := strings.TrimSpace(lines[index])
+				if indentation(lines[index]) < 4 && strings.HasPrefix(closing, fence) && strings.Trim(closing, fence[:1]) == "" {
+					index++
+					break
+				}
+
+				code = append(code, stripIndentation(lines[index], fenceIndentation))
+			}
+
+			text := strings.Join(code, "\n")
+			if len(code) > 0 {
+				text += "\n"
+			}
+
+			blocks = append(blocks, &block{kind: codeBlock, text: text, info: info})
+
+			continue
+		}
+
+		// ATX heading
+		if matches := atxHeadingRegexp.FindStringSubmatch(line); matches != nil {
+			text, attributes := parseHeadingAttributes(matches[2])
+			blocks = append(blocks, &block{kind: headingBlock, level: len(matches[1]), text: text, attributes: attributes})
+			index++
+
+			continue
+		}
+
+		// thematic break
+		if thematicBreakRegexp.MatchString(line) {
+			blocks = append(blocks, &block{kind: thematicBreakBlock})
+			index++
+
+			continue
+		}
+
+		// block quote
+		if quoteRegexp.MatchString(line) {
+			var quote []string
+
+			for index < len(lines) {
+				current := lines[index]
+
+				if prefix := quoteRegexp.FindString(current); prefix != "" {
+					quote = append(quote, current[len(prefix):])
+					index++
+					continue
+				}
+
+				if isBlank(current) || !isLazyContinuation(quote, current) {
+					break
+				}
+
+				quote = append(quote, current)
+				index++
+			}
+
+			blocks = append(blocks, &block{kind: quoteBlock, children: p.parseBlocks(quote)})
+
+			continue
+		}
+
+		// list
+		if marker, ok := parseListMarker(line); ok {
+			var list *block
+
+			list, index = p.parseList(lines, index, marker)
+			blocks = append(blocks, list)
+
+			continue
+		}
+
+		// HTML block
+		if kind := htmlBlockKind(line); kind >= 0 {
+			var html []string
+
+			for ; index < len(lines); index++ {
+				current := lines[index]
+
+				if htmlBlockEnds[kind] == nil && isBlank(current) {
+					break
+				}
+
+				html = append(html, current)
+
+				if htmlBlockEnds[kind] != nil && htmlBlockEnds[kind].MatchString(current) {
+					index++
+					break
+				}
+			}
+
+			blocks = append(blocks, &block{kind: htmlBlock, text: strings.Join(html, "\n")})
+
+			continue
+		}
+
+		// table
+		if index+1 < len(lines) && strings.Contains(line, "|") && tableDelimiterRow.MatchString(lines[index+1]) {
+			header := splitTableRow(line)
+			alignments := getTableAlignments(lines[index+1])
+
+			if len(header) == len(alignments) {
+				table := &block{kind: tableBlock, header: header, alignments: alignments}
+
+				for index += 2; index < len(lines); index++ {
+					current := lines[index]
+					if isBlank(current) || interruptsParagraph(current) {
+						break
+					}
+
+					row := splitTableRow(current)
+					for len(row) < len(header) {
+						row = append(row, "")
+					}
+
+					table.rows = append(table.rows, row[:len(header)])
+				}
+
+				blocks = append(blocks, table)
+
+				continue
+			}
+		}
+
+		// paragraph, possibly turning into a setext heading
+		var paragraph []string
+		level := 0
+
+		for ; index < len(lines); index++ {
+			current := lines[index]
+
+			if isBlank(current) {
+				break
+			}
+
+			if len(paragraph) > 0 {
+				if matches := setextHeadingRegexp.FindStringSubmatch(current); matches != nil {
+					level = 1
+					if matches[1][0] == '-' {
+						level = 2
+					}
+
+					index++
+					break
+				}
+
+				if interruptsParagraph(current) {
+					break
+				}
+			}
+
+			paragraph = append(paragraph, strings.TrimLeft(current, " \t"))
+		}
+
+		text := p.parseReferences(strings.Join(paragraph, "\n"))
+
+		if level > 0 && text != "" {
+			// Only the last line of a paragraph followed by a setext underline
+			// may contain heading attributes.
+			text, attributes := parseHeadingAttributes(text)
+			blocks = append(blocks, &block{kind: headingBlock, level: level, text: text, attributes: attributes})
+
+			continue
+		}
+
+		if level > 0 {
+			// A setext underline following only link reference definitions is a paragraph.
+			text = strings.TrimSpace(lines[index-1])
+		}
+
+		if text != "" {
+			blocks = append(blocks, &block{kind: paragraphBlock, text: strings.TrimRight(text, " \t")})
+		}
+	}
+
+	return blocks
+}
+
+// parseList parses the list starting at the given line index and returns the list
+// together with the index of the first line after the list.
+func (p *parser) parseList(lines []string, index int, marker *listMarker) (*block, int) {
+	list := &block{kind: listBlock, ordered: marker.ordered, start: marker.start, tight: true}
+
+	for index < len(lines) {
+		line := lines[index]
+
+		current, ok := parseListMarker(line)
+		if !ok || current.ordered != marker.ordered || current.bullet != marker.bullet || current.delimiter != marker.delimiter || thematicBreakRegexp.MatchString(line) {
+			break
+		}
+
+		var item []string
+
+		if !current.empty {
+			item = append(item, expandTabs(line)[current.indent:])
+		}
+
+		for index++; index < len(lines); index++ {
+			next := lines[index]
+
+			if isBlank(next) {
+				// A list item can begin with at most one blank line.
+				if len(item) == 0 {
+					break
+				}
+
+				item = append(item, "")
+				continue
+			}
+
+			if indentation(next) >= current.indent {
+				item = append(item, stripIndentation(next, current.indent))
+				continue
+			}
+
+			if _, isListItem := parseListMarker(next); !isListItem && isLazyContinuation(item, next) {
+				item = append(item, next)
+				continue
+			}
+
+			break
+		}
+
+		// Trailing blank lines separate list items, which makes the list loose.
+		trimmed := trimBlankLines(item)
+		hasTrailingBlankLines := len(trimmed) < len(item)
+		isLastItem := index >= len(lines)
+		if !isLastItem {
+			next, isListItem := parseListMarker(lines[index])
+			isLastItem = !isListItem || next.ordered != marker.ordered || next.bullet != marker.bullet || next.delimiter != marker.delimiter
+		}
+
+		children := p.parseBlocks(trimmed)
+		if len(children) > 1 && containsBlankLine(trimmed) {
+			list.tight = false
+		}
+		if hasTrailingBlankLines && !isLastItem {
+			list.tight = false
+		}
+
+		listItem := &block{kind: itemBlock, children: children}
+
+		// GFM task list items, e.g. "- [x] done"
+		if len(children) > 0 && children[0].kind == paragraphBlock {
+			text := children[0].text
+
+			if len(text) >= 3 && text[0] == '[' && text[2] == ']' && (len(text) == 3 || text[3] == ' ' || text[3] == '\n') {
+				switch text[1] {
+				case ' ':
+					listItem.task = 1
+				case 'x', 'X':
+					listItem.task = 2
+				}
+
+				if listItem.task > 0 {
+					children[0].text = strings.TrimLeft(text[3:], " ")
+				}
+			}
+		}
+
+		list.children = append(list.children, listItem)
+	}
+
+	return list, index
+}
+
+// parseReferences removes link reference definitions from the start of a paragraph
+// and returns the remaining text.
+func (p *parser) parseReferences(text string) string {
+	for {
+		matches := referenceRegexp.FindStringSubmatch(text)
+		if matches == nil {
+			return text
+		}
+
+		label := normalizeLabel(matches[1])
+		if label == "" {
+			return text
+		}
+
+		// A destination in pointy brackets must be closed, e.g. "[a]: <b" is no definition.
+		destination := matches[2]
+		if len(destination) >= 2 && strings.HasPrefix(destination, "<") && strings.HasSuffix(destination, ">") {
+			destination = destination[1 : len(destination)-1]
+		}
+
+		title := matches[3]
+		if title != "" {
+			title = title[1 : len(title)-1]
+		}
+
+		// The first definition of a label takes precedence.
+		if _, exists := p.references[label]; !exists {
+			p.references[label] = &reference{
+				destination: unescapeString(destination),
+				title:       unescapeString(title),
+			}
+		}
+
+		text = text[len(matches[0]):]
+	}
+}
+
+func parseListMarker(line string) (*listMarker, bool) {
+	line = expandTabs(line)
+
+	matches := listMarkerRegexp.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, false
+	}
+
+	marker := &listMarker{}
+	symbol := matches[2]
+
+	if number, err := strconv.Atoi(symbol[:len(symbol)-1]); err == nil {
+		marker.ordered = true
+		marker.start = number
+		marker.delimiter = symbol[len(symbol)-1]
+	} else {
+		marker.bullet = symbol[0]
+	}
+
+	markerEnd := len(matches[1]) + len(symbol)
+	spaces := len(matches[3])
+
+	switch {
+	case isBlank(line[markerEnd:]):
+		marker.empty = true
+		marker.indent = markerEnd + 1
+	case spaces > 4:
+		// The content is an indented code block, starting one space after the marker.
+		marker.indent = markerEnd + 1
+	default:
+		marker.indent = markerEnd + spaces
+	}
+
+	return marker, true
+}
+
+func parseHeadingAttributes(text string) (string, *attributes) {
+	headingAttributes := &attributes{values: make(map[string]string)}
+
+	matches := attributesRegexp.FindStringSubmatchIndex(text)
+	if matches == nil {
+		return strings.TrimSpace(text), headingAttributes
+	}
+
+	for _, field := range strings.Fields(text[matches[2]:matches[3]]) {
+		switch {
+		case field == "-":
+			// Pandoc shorthand for unnumbered headings
+			headingAttributes.classes = append(headingAttributes.classes, "unnumbered")
+		case strings.HasPrefix(field, "#"):
+			headingAttributes.id = field[1:]
+		case strings.HasPrefix(field, "."):
+			headingAttributes.classes = append(headingAttributes.classes, field[1:])
+		case strings.Contains(field, "="):
+			key, value, _ := strings.Cut(field, "=")
+			headingAttributes.keys = append(headingAttributes.keys, key)
+			headingAttributes.values[key] = strings.Trim(value, `"'`)
+		default:
+			// not an attribute list, e.g. a literal brace in the heading
+			return strings.TrimSpace(text), &attributes{values: make(map[string]string)}
+		}
+	}
+
+	return strings.TrimSpace(text[:matches[0]]), headingAttributes
+}
+
+func htmlBlockKind(line string) int {
+	for kind, start := range htmlBlockStarts {
+		if start.MatchString(line) {
+			return kind
+		}
+	}
+
+	return -1
+}
+
+// interruptsParagraph reports whether the given line starts a block that can interrupt a paragraph.
+func interruptsParagraph(line string) bool {
+	if atxHeadingRegexp.MatchString(line) || thematicBreakRegexp.MatchString(line) || quoteRegexp.MatchString(line) {
+		return true
+	}
+
+	if matches := fenceRegexp.FindStringSubmatch(line); matches != nil && !(matches[2][0] == '`' && strings.Contains(matches[3], "`")) {
+		return true
+	}
+
+	// HTML blocks of the last kind cannot interrupt a paragraph.
+	if kind := htmlBlockKind(line); kind >= 0 && kind < len(htmlBlockStarts)-1 {
+		return true
+	}
+
+	// Only non-empty bullet lists and ordered lists starting with 1 can interrupt a paragraph.
+	if marker, ok := parseListMarker(line); ok && !marker.empty && (!marker.ordered || marker.start == 1) {
+		return true
+	}
+
+	return false
+}
+
+// isLazyContinuation reports whether the given line continues a paragraph at the end of the
+// given lines of a container block, even though the line lacks the container's prefix.
+func isLazyContinuation(lines []string, line string) bool {
+	if len(lines) == 0 || isBlank(lines[len(lines)-1]) || interruptsParagraph(line) {
+		return false
+	}
+
+	// Within an open fenced code block there is no paragraph to continue.
+	fences := 0
+	for _, current := range lines {
+		if fenceRegexp.MatchString(current) {
+			fences++
+		}
+	}
+
+	last := lines[len(lines)-1]
+
+	return fences%2 == 0 && indentation(last) < 4 && !atxHeadingRegexp.MatchString(last) && !thematicBreakRegexp.MatchString(last)
+}
+
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+
+	for index := 0; index < len(line); index++ {
+		switch {
+		case line[index] == '\\' && index+1 < len(line) && line[index+1] == '|':
+			cell.WriteByte('|')
+			index++
+		case line[index] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[index])
+		}
+	}
+
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+func getTableAlignments(line string) []string {
+	var alignments []string
+
+	for _, cell := range splitTableRow(line) {
+		isLeft := strings.HasPrefix(cell, ":")
+		isRight := strings.HasSuffix(cell, ":")
+
+		switch {
+		case isLeft && isRight:
+			alignments = append(alignments, "center")
+		case isLeft:
+			alignments = append(alignments, "left")
+		case isRight:
+			alignments = append(alignments, "right")
+		default:
+			alignments = append(alignments, "")
+		}
+	}
+
+	return alignments
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// indentation returns the number of columns of leading whitespace, with tab stops of 4 columns.
+func indentation(line string) int {
+	columns := 0
+
+	for _, character := range line {
+		switch character {
+		case ' ':
+			columns++
+		case '\t':
+			columns += 4 - columns%4
+		default:
+			return columns
+		}
+	}
+
+	return columns
+}
+
+// stripIndentation removes up to the given number of columns of leading whitespace.
+func stripIndentation(line string, columns int) string {
+	line = expandTabs(line)
+
+	for index := 0; index < columns; index++ {
+		if index >= len(line) || line[index] != ' ' {
+			return line[index:]
+		}
+	}
+
+	return line[columns:]
+}
+
+// expandTabs replaces tabs within the leading whitespace of a line with spaces.
+func expandTabs(line string) string {
+	columns := indentation(line)
+	whitespace := len(line) - len(strings.TrimLeft(line, " \t"))
+
+	if !strings.Contains(line[:whitespace], "\t") {
+		return line
+	}
+
+	return strings.Repeat(" ", columns) + line[whitespace:]
+}
+
+func trimBlankLines(lines []string) []string {
+	for len(lines) > 0 && isBlank(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+func containsBlankLine(lines []string) bool {
+	for _, line := range lines {
+		if isBlank(line) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,622 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type inline struct {
+	html      string // rendered HTML of text, code spans, links, etc.
+	delimiter byte   // "*", "_" or "~" for emphasis delimiter runs
+	count     int    // number of remaining delimiter characters
+	length    int    // original number of delimiter characters
+	canOpen   bool
+	canClose  bool
+	bracket   int  // 1 for "[", 2 for "![", which may start a link or an image
+	active    bool // brackets within a link can no longer start a link
+	position  int  // position in the source text after a bracket
+	openTags  string
+	closeTags string
+}
+
+type reference struct {
+	destination string
+	title       string
+}
+
+var (
+	entityRegexp         = regexp.MustCompile(`^&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[A-Za-z][A-Za-z0-9]{1,31});`)
+	autolinkRegexp       = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^\s<>]*)>`)
+	emailRegexp          = regexp.MustCompile(`^<([a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*)>`)
+	rawHtmlRegexp        = regexp.MustCompile(`^(?:<[A-Za-z][A-Za-z0-9-]*(?:\s+[A-Za-z_:][A-Za-z0-9_.:-]*(?:\s*=\s*(?:[^\s"'=<>` + "`" + `]+|'[^']*'|"[^"]*"))?)*\s*/?>|</[A-Za-z][A-Za-z0-9-]*\s*>|<!---->|<!--(?:-?[^>-])(?:-?[^-])*-->|<\?.*?\?>|<![A-Z]+\s+[^>]*>|<!\[CDATA\[[\s\S]*?\]\]>)`)
+	bareAutolinkRegexp   = regexp.MustCompile(`^(?:https?://|www\.)[^\s<]*`)
+	trailingEntityRegexp = regexp.MustCompile(`&[A-Za-z0-9]+;$`)
+)
+
+// parseInlines renders the inline content of a paragraph, heading or table cell.
+func (p *parser) parseInlines(text string) string {
+	var nodes []*inline
+	var buffer strings.Builder
+
+	flush := func() {
+		if buffer.Len() > 0 {
+			nodes = append(nodes, &inline{html: buffer.String()})
+			buffer.Reset()
+		}
+	}
+
+	for index := 0; index < len(text); {
+		character := text[index]
+
+		switch character {
+		case '\\':
+			if index+1 < len(text) && text[index+1] == '\n' {
+				buffer.WriteString("<br />\n")
+				index += 2
+				continue
+			}
+
+			if index+1 < len(text) && isASCIIPunctuation(text[index+1]) {
+				buffer.WriteString(escape(text[index+1 : index+2]))
+				index += 2
+				continue
+			}
+
+			buffer.WriteByte('\\')
+			index++
+
+		case '`':
+			length := runLength(text, index)
+			end := findBacktickRun(text, index+length, length)
+
+			if end < 0 {
+				buffer.WriteString(text[index : index+length])
+				index += length
+				continue
+			}
+
+			code := strings.ReplaceAll(text[index+length:end], "\n", " ")
+			if len(code) >= 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+
+			buffer.WriteString("<code>" + escape(code) + "</code>")
+			index = end + length
+
+		case '*', '_', '~':
+			length := runLength(text, index)
+
+			if character == '~' && length > 2 {
+				buffer.WriteString(text[index : index+length])
+				index += length
+				continue
+			}
+
+			before, _ := utf8.DecodeLastRuneInString(text[:index])
+			if index == 0 {
+				before = '\n'
+			}
+
+			after, _ := utf8.DecodeRuneInString(text[index+length:])
+			if index+length >= len(text) {
+				after = '\n'
+			}
+
+			isLeftFlanking := !unicode.IsSpace(after) && (!isPunctuation(after) || unicode.IsSpace(before) || isPunctuation(before))
+			isRightFlanking := !unicode.IsSpace(before) && (!isPunctuation(before) || unicode.IsSpace(after) || isPunctuation(after))
+
+			node := &inline{delimiter: character, count: length, length: length}
+
+			if character == '_' {
+				node.canOpen = isLeftFlanking && (!isRightFlanking || isPunctuation(before))
+				node.canClose = isRightFlanking && (!isLeftFlanking || isPunctuation(after))
+			} else {
+				node.canOpen = isLeftFlanking
+				node.canClose = isRightFlanking
+			}
+
+			flush()
+			nodes = append(nodes, node)
+			index += length
+
+		case '[':
+			flush()
+			nodes = append(nodes, &inline{html: "[", bracket: 1, active: true, position: index + 1})
+			index++
+
+		case '!':
+			if index+1 < len(text) && text[index+1] == '[' {
+				flush()
+				nodes = append(nodes, &inline{html: "![", bracket: 2, active: true, position: index + 2})
+				index += 2
+				continue
+			}
+
+			buffer.WriteByte('!')
+			index++
+
+		case ']':
+			flush()
+
+			opener := -1
+			for current := len(nodes) - 1; current >= 0; current-- {
+				if nodes[current].bracket > 0 {
+					opener = current
+					break
+				}
+			}
+
+			if opener < 0 {
+				buffer.WriteByte(']')
+				index++
+				continue
+			}
+
+			bracket := nodes[opener]
+			if !bracket.active {
+				bracket.bracket = 0
+				buffer.WriteByte(']')
+				index++
+				continue
+			}
+
+			destination, title, consumed, ok := p.parseLinkTail(text[index+1:], text[bracket.position:index])
+			if !ok {
+				bracket.bracket = 0
+				buffer.WriteByte(']')
+				index++
+				continue
+			}
+
+			inner := nodes[opener+1:]
+			processEmphasis(inner)
+			content := renderInlines(inner)
+
+			var link string
+			if bracket.bracket == 2 {
+				link = fmt.Sprintf(`<img src="%s" alt="%s"`, escape(normalizeURI(destination)), escape(stripTags(content)))
+				if title != "" {
+					link += fmt.Sprintf(` title="%s"`, escape(title))
+				}
+				link += " />"
+			} else {
+				link = fmt.Sprintf(`<a href="%s"`, escape(normalizeURI(destination)))
+				if title != "" {
+					link += fmt.Sprintf(` title="%s"`, escape(title))
+				}
+				link += ">" + content + "</a>"
+
+				// Links cannot contain other links.
+				for _, node := range nodes[:opener] {
+					if node.bracket == 1 {
+						node.active = false
+					}
+				}
+			}
+
+			nodes = append(nodes[:opener], &inline{html: link})
+			index += 1 + consumed
+
+		case '<':
+			if matches := autolinkRegexp.FindStringSubmatch(text[index:]); matches != nil {
+				buffer.WriteString(fmt.Sprintf(`<a href="%s">%s</a>`, escape(normalizeURI(matches[1])), escape(matches[1])))
+				index += len(matches[0])
+				continue
+			}
+
+			if matches := emailRegexp.FindStringSubmatch(text[index:]); matches != nil {
+				buffer.WriteString(fmt.Sprintf(`<a href="mailto:%s">%s</a>`, escape(normalizeURI(matches[1])), escape(matches[1])))
+				index += len(matches[0])
+				continue
+			}
+
+			if match := rawHtmlRegexp.FindString(text[index:]); match != "" {
+				buffer.WriteString(match)
+				index += len(match)
+				continue
+			}
+
+			buffer.WriteString("&lt;")
+			index++
+
+		case '&':
+			if match := entityRegexp.FindString(text[index:]); match != "" {
+				buffer.WriteString(match)
+				index += len(match)
+				continue
+			}
+
+			buffer.WriteString("&amp;")
+			index++
+
+		case '\n':
+			// Two or more trailing spaces make a hard line break.
+			line := buffer.String()
+			trimmed := strings.TrimRight(line, " ")
+			buffer.Reset()
+			buffer.WriteString(trimmed)
+
+			if len(line)-len(trimmed) >= 2 {
+				buffer.WriteString("<br />")
+			}
+
+			buffer.WriteByte('\n')
+			index++
+
+			for index < len(text) && text[index] == ' ' {
+				index++
+			}
+
+		default:
+			// GFM extended autolinks, e.g. "www.example.com" or "https://example.com"
+			if (character == 'h' || character == 'w') && isAutolinkBoundary(text, index) {
+				if link := getBareAutolink(text[index:]); link != "" {
+					href := link
+					if strings.HasPrefix(link, "www.") {
+						href = "http://" + link
+					}
+
+					buffer.WriteString(fmt.Sprintf(`<a href="%s">%s</a>`, escape(normalizeURI(href)), escape(link)))
+					index += len(link)
+					continue
+				}
+			}
+
+			buffer.WriteString(escape(text[index : index+1]))
+			index++
+		}
+	}
+
+	flush()
+	processEmphasis(nodes)
+
+	return renderInlines(nodes)
+}
+
+// parseLinkTail parses what follows the closing bracket of a link or an image, i.e. an inline
+// destination with an optional title, or a reference to a link reference definition. The label
+// is the text between the brackets. It returns the number of consumed bytes after the bracket.
+func (p *parser) parseLinkTail(text string, label string) (string, string, int, bool) {
+	if strings.HasPrefix(text, "(") {
+		if destination, title, consumed, ok := parseInlineLink(text); ok {
+			return destination, title, consumed, true
+		}
+	}
+
+	if strings.HasPrefix(text, "[") {
+		end := strings.Index(text, "]")
+		if end > 0 {
+			// full reference link, e.g. [text][label]
+			if reference, ok := p.references[normalizeLabel(text[1:end])]; ok {
+				return reference.destination, reference.title, end + 1, true
+			}
+
+			return "", "", 0, false
+		}
+
+		// collapsed reference link, e.g. [label][]
+		if end == 1 {
+			if reference, ok := p.references[normalizeLabel(label)]; ok {
+				return reference.destination, reference.title, 2, true
+			}
+		}
+	}
+
+	// shortcut reference link, e.g. [label]
+	if reference, ok := p.references[normalizeLabel(label)]; ok {
+		return reference.destination, reference.title, 0, true
+	}
+
+	return "", "", 0, false
+}
+
+// parseInlineLink parses an inline link destination with an optional title in parentheses,
+// e.g. (https://example.com "Title").
+func parseInlineLink(text string) (string, string, int, bool) {
+	index := skipWhitespace(text, 1)
+
+	var destination string
+
+	if index < len(text) && text[index] == '<' {
+		end := strings.IndexAny(text[index+1:], "<>\n")
+		if end < 0 || text[index+1+end] != '>' {
+			return "", "", 0, false
+		}
+
+		destination = text[index+1 : index+1+end]
+		index += end + 2
+	} else {
+		start := index
+		depth := 0
+
+		for ; index < len(text); index++ {
+			character := text[index]
+
+			if character == '\\' && index+1 < len(text) && isASCIIPunctuation(text[index+1]) {
+				index++
+				continue
+			}
+
+			if character == '(' {
+				depth++
+			}
+
+			if character == ')' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			}
+
+			if character <= ' ' {
+				break
+			}
+		}
+
+		destination = text[start:index]
+	}
+
+	afterDestination := index
+	index = skipWhitespace(text, index)
+
+	var title string
+
+	if index < len(text) && index > afterDestination && strings.ContainsRune(`"'(`, rune(text[index])) {
+		closing := text[index]
+		if closing == '(' {
+			closing = ')'
+		}
+
+		end := index + 1
+		for ; end < len(text) && text[end] != closing; end++ {
+			if text[end] == '\\' {
+				end++
+			}
+		}
+
+		if end >= len(text) {
+			return "", "", 0, false
+		}
+
+		title = text[index+1 : end]
+		index = skipWhitespace(text, end+1)
+	}
+
+	if index >= len(text) || text[index] != ')' {
+		return "", "", 0, false
+	}
+
+	return unescapeString(destination), unescapeString(title), index + 1, true
+}
+
+// processEmphasis matches opening and closing delimiter runs and adds the corresponding tags.
+// See: https://spec.commonmark.org/0.30/#process-emphasis
+func processEmphasis(nodes []*inline) {
+	for closerIndex := 0; closerIndex < len(nodes); closerIndex++ {
+		closer := nodes[closerIndex]
+
+		for closer.delimiter != 0 && closer.canClose && closer.count > 0 {
+			openerIndex := -1
+
+			for current := closerIndex - 1; current >= 0; current-- {
+				opener := nodes[current]
+
+				if opener.delimiter != closer.delimiter || !opener.canOpen || opener.count == 0 {
+					continue
+				}
+
+				// rule of three
+				if (opener.canClose || closer.canOpen) && (opener.length+closer.length)%3 == 0 && !(opener.length%3 == 0 && closer.length%3 == 0) {
+					continue
+				}
+
+				if closer.delimiter == '~' && opener.count != closer.count {
+					continue
+				}
+
+				openerIndex = current
+				break
+			}
+
+			if openerIndex < 0 {
+				break
+			}
+
+			opener := nodes[openerIndex]
+
+			count := 1
+			if opener.count >= 2 && closer.count >= 2 {
+				count = 2
+			}
+
+			tag := "em"
+			switch {
+			case closer.delimiter == '~':
+				tag = "del"
+				count = closer.count
+			case count == 2:
+				tag = "strong"
+			}
+
+			opener.count -= count
+			closer.count -= count
+			opener.openTags = "<" + tag + ">" + opener.openTags
+			closer.closeTags += "</" + tag + ">"
+
+			// Delimiters between the opener and the closer can no longer be matched.
+			for _, node := range nodes[openerIndex+1 : closerIndex] {
+				if node.delimiter != 0 {
+					node.canOpen = false
+					node.canClose = false
+				}
+			}
+		}
+	}
+}
+
+func renderInlines(nodes []*inline) string {
+	var stringBuilder strings.Builder
+
+	for _, node := range nodes {
+		if node.delimiter != 0 {
+			stringBuilder.WriteString(node.closeTags)
+			stringBuilder.WriteString(strings.Repeat(string(node.delimiter), node.count))
+			stringBuilder.WriteString(node.openTags)
+			continue
+		}
+
+		stringBuilder.WriteString(node.html)
+	}
+
+	return stringBuilder.String()
+}
+
+// getBareAutolink returns the URL at the start of the given text, without trailing punctuation.
+// See: https://github.github.com/gfm/#autolinks-extension-
+func getBareAutolink(text string) string {
+	link := bareAutolinkRegexp.FindString(text)
+
+	for link != "" {
+		last := link[len(link)-1]
+
+		switch {
+		case strings.IndexByte(`?!.,:*_~'"`, last) >= 0:
+			link = link[:len(link)-1]
+		case last == ')' && strings.Count(link, "(") < strings.Count(link, ")"):
+			link = link[:len(link)-1]
+		case last == ';' && trailingEntityRegexp.MatchString(link):
+			link = link[:strings.LastIndex(link, "&")]
+		default:
+			host := strings.TrimPrefix(strings.TrimPrefix(link, "http://"), "https://")
+			host, _, _ = strings.Cut(host, "/")
+
+			// A valid domain contains at least one period.
+			if !strings.Contains(host, ".") {
+				return ""
+			}
+
+			return link
+		}
+	}
+
+	return ""
+}
+
+func isAutolinkBoundary(text string, index int) bool {
+	if index == 0 {
+		return true
+	}
+
+	before, _ := utf8.DecodeLastRuneInString(text[:index])
+
+	return unicode.IsSpace(before) || strings.ContainsRune("*_~(", before)
+}
+
+func findBacktickRun(text string, start int, length int) int {
+	for index := start; index < len(text); {
+		if text[index] != '`' {
+			index++
+			continue
+		}
+
+		current := runLength(text, index)
+		if current == length {
+			return index
+		}
+
+		index += current
+	}
+
+	return -1
+}
+
+func runLength(text string, index int) int {
+	length := 0
+
+	for index+length < len(text) && text[index+length] == text[index] {
+		length++
+	}
+
+	return length
+}
+
+func skipWhitespace(text string, index int) int {
+	for index < len(text) && (text[index] == ' ' || text[index] == '\t' || text[index] == '\n') {
+		index++
+	}
+
+	return index
+}
+
+func isASCIIPunctuation(character byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", character) >= 0
+}
+
+func isPunctuation(character rune) bool {
+	return unicode.IsPunct(character) || unicode.IsSymbol(character)
+}
+
+// normalizeLabel case-folds a link label and collapses its whitespace.
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+// unescapeString resolves backslash escapes and entity references.
+func unescapeString(text string) string {
+	var stringBuilder strings.Builder
+
+	for index := 0; index < len(text); index++ {
+		if text[index] == '\\' && index+1 < len(text) && isASCIIPunctuation(text[index+1]) {
+			index++
+		}
+
+		stringBuilder.WriteByte(text[index])
+	}
+
+	return html.UnescapeString(stringBuilder.String())
+}
+
+// normalizeURI percent-encodes all characters of a link destination that are not allowed in URIs.
+func normalizeURI(uri string) string {
+	var stringBuilder strings.Builder
+
+	for index := 0; index < len(uri); index++ {
+		character := uri[index]
+
+		isHex := func(character byte) bool {
+			return strings.IndexByte("0123456789abcdefABCDEF", character) >= 0
+		}
+
+		switch {
+		case character == '%' && index+2 < len(uri) && isHex(uri[index+1]) && isHex(uri[index+2]):
+			stringBuilder.WriteByte(character)
+		case character < 0x80 && (unicode.IsLetter(rune(character)) || unicode.IsDigit(rune(character)) || strings.IndexByte(";/?:@&=+$,-_.!~*'()#", character) >= 0):
+			stringBuilder.WriteByte(character)
+		default:
+			stringBuilder.WriteString(fmt.Sprintf("%%%02X", character))
+		}
+	}
+
+	return stringBuilder.String()
+}
+
+func escape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(text)
+}
+
+var tagRegexp = regexp.MustCompile(`<[^>]*>`)
+
+// stripTags returns the text content of the given HTML, e.g. for the alternative text of images.
+func stripTags(text string) string {
+	return tagRegexp.ReplaceAllString(text, "")
+}
@@ -0,0 +1,359 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+// Package markdown converts CommonMark documents, including the GitHub Flavored Markdown
+// extensions for tables, task lists, strikethrough and autolinks, to HTML.
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+type parser struct {
+	references map[string]*reference
+	ids        map[string]int
+}
+
+var frontMatterRegexp = regexp.MustCompile(`^---[ \t]*\n((?s).*?)\n(?:---|\.\.\.)[ \t]*(?:\n|$)`)
+
+// Html converts a Markdown text to an HTML fragment.
+func Html(source []byte) []byte {
+	_, body := parseFrontMatter(string(source))
+
+	return []byte(newParser().render(body))
+}
+
+// Document converts a Markdown document to a complete HTML document. A YAML front matter
+// block at the start of the document, as used by Pandoc, provides the title and the meta data
+// of the document head, e.g. "title", "author", "date", "lang", "description" and "keywords".
+// Without a title, the text of the first heading is used.
+func Document(source []byte) []byte {
+	metaData, body := parseFrontMatter(string(source))
+	parser := newParser()
+	content := parser.render(body)
+
+	var stringBuilder strings.Builder
+
+	stringBuilder.WriteString("<!DOCTYPE html>\n")
+
+	if lang := metaData.get("lang"); lang != "" {
+		stringBuilder.WriteString(fmt.Sprintf("<html lang=\"%s\">\n", escape(lang)))
+	} else {
+		stringBuilder.WriteString("<html>\n")
+	}
+
+	stringBuilder.WriteString("<head>\n<meta charset=\"utf-8\" />\n")
+
+	title := metaData.get("title")
+	if title == "" {
+		title = getFirstHeading(content)
+	}
+	if title != "" {
+		stringBuilder.WriteString(fmt.Sprintf("<title>%s</title>\n", escape(title)))
+	}
+
+	for _, key := range metaData.keys {
+		name := key
+
+		switch key {
+		case "title", "lang":
+			continue
+		case "date":
+			name = "dcterms.date" // same name as used by Pandoc
+		case "keywords":
+			stringBuilder.WriteString(fmt.Sprintf("<meta name=\"keywords\" content=\"%s\" />\n", escape(strings.Join(metaData.values[key], ", "))))
+			continue
+		}
+
+		for _, value := range metaData.values[key] {
+			stringBuilder.WriteString(fmt.Sprintf("<meta name=\"%s\" content=\"%s\" />\n", escape(name), escape(value)))
+		}
+	}
+
+	stringBuilder.WriteString("</head>\n<body>\n")
+	stringBuilder.WriteString(content)
+	stringBuilder.WriteString("</body>\n</html>\n")
+
+	return []byte(stringBuilder.String())
+}
+
+func newParser() *parser {
+	return &parser{
+		references: make(map[string]*reference),
+		ids:        make(map[string]int),
+	}
+}
+
+func (p *parser) render(source string) string {
+	source = strings.TrimPrefix(source, "\uFEFF")
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	source = strings.ReplaceAll(source, "\r", "\n")
+	source = strings.ReplaceAll(source, "\x00", "\uFFFD")
+
+	// Blocks are parsed completely before inlines, since links may refer to
+	// link reference definitions further down in the document. The final line
+	// ending ends the last line and doesn't start an empty one, e.g. in an
+	// unclosed fenced code block.
+	blocks := p.parseBlocks(strings.Split(strings.TrimSuffix(source, "\n"), "\n"))
+
+	var stringBuilder strings.Builder
+
+	p.renderBlocks(&stringBuilder, blocks, false)
+
+	return stringBuilder.String()
+}
+
+func (p *parser) renderBlocks(stringBuilder *strings.Builder, blocks []*block, tight bool) {
+	for _, current := range blocks {
+		switch current.kind {
+		case paragraphBlock:
+			if tight {
+				stringBuilder.WriteString(p.parseInlines(current.text))
+				continue
+			}
+
+			stringBuilder.WriteString("<p>" + p.parseInlines(current.text) + "</p>\n")
+
+		case headingBlock:
+			content := p.parseInlines(current.text)
+
+			id := current.attributes.id
+			if id == "" {
+				id = p.getHeadingId(html.UnescapeString(stripTags(content)))
+			}
+
+			stringBuilder.WriteString(fmt.Sprintf("<h%d id=\"%s\"", current.level, escape(id)))
+			if len(current.attributes.classes) > 0 {
+				stringBuilder.WriteString(fmt.Sprintf(" class=\"%s\"", escape(strings.Join(current.attributes.classes, " "))))
+			}
+			for _, key := range current.attributes.keys {
+				stringBuilder.WriteString(fmt.Sprintf(" %s=\"%s\"", escape(key), escape(current.attributes.values[key])))
+			}
+			stringBuilder.WriteString(fmt.Sprintf(">%s</h%d>\n", content, current.level))
+
+		case codeBlock:
+			stringBuilder.WriteString("<pre><code")
+			if language, _, _ := strings.Cut(current.info, " "); language != "" {
+				stringBuilder.WriteString(fmt.Sprintf(" class=\"language-%s\"", escape(language)))
+			}
+			stringBuilder.WriteString(">" + escape(current.text) + "</code></pre>\n")
+
+		case htmlBlock:
+			stringBuilder.WriteString(current.text + "\n")
+
+		case thematicBreakBlock:
+			stringBuilder.WriteString("<hr />\n")
+
+		case quoteBlock:
+			stringBuilder.WriteString("<blockquote>\n")
+			p.renderBlocks(stringBuilder, current.children, false)
+			stringBuilder.WriteString("</blockquote>\n")
+
+		case listBlock:
+			tag := "ul"
+			if current.ordered {
+				tag = "ol"
+			}
+
+			if current.ordered && current.start != 1 {
+				stringBuilder.WriteString(fmt.Sprintf("<ol start=\"%d\">\n", current.start))
+			} else {
+				stringBuilder.WriteString("<" + tag + ">\n")
+			}
+
+			p.renderBlocks(stringBuilder, current.children, current.tight)
+			stringBuilder.WriteString("</" + tag + ">\n")
+
+		case itemBlock:
+			stringBuilder.WriteString("<li>")
+
+			switch current.task {
+			case 1:
+				stringBuilder.WriteString("<input type=\"checkbox\" disabled=\"\" /> ")
+			case 2:
+				stringBuilder.WriteString("<input type=\"checkbox\" checked=\"\" disabled=\"\" /> ")
+			}
+
+			// Nested blocks of loose list items start on their own line.
+			if len(current.children) > 0 && (!tight || current.children[0].kind != paragraphBlock) {
+				stringBuilder.WriteString("\n")
+			}
+
+			for index, child := range current.children {
+				p.renderBlocks(stringBuilder, []*block{child}, tight)
+
+				isTightParagraph := tight && child.kind == paragraphBlock
+				if isTightParagraph && index < len(current.children)-1 {
+					stringBuilder.WriteString("\n")
+				}
+			}
+
+			stringBuilder.WriteString("</li>\n")
+
+		case tableBlock:
+			stringBuilder.WriteString("<table>\n<thead>\n")
+			p.renderTableRow(stringBuilder, "th", current.header, current.alignments)
+			stringBuilder.WriteString("</thead>\n")
+
+			if len(current.rows) > 0 {
+				stringBuilder.WriteString("<tbody>\n")
+				for _, row := range current.rows {
+					p.renderTableRow(stringBuilder, "td", row, current.alignments)
+				}
+				stringBuilder.WriteString("</tbody>\n")
+			}
+
+			stringBuilder.WriteString("</table>\n")
+		}
+	}
+}
+
+func (p *parser) renderTableRow(stringBuilder *strings.Builder, tag string, cells []string, alignments []string) {
+	stringBuilder.WriteString("<tr>\n")
+
+	for index, cell := range cells {
+		if alignments[index] != "" {
+			stringBuilder.WriteString(fmt.Sprintf("<%s align=\"%s\">", tag, alignments[index]))
+		} else {
+			stringBuilder.WriteString("<" + tag + ">")
+		}
+
+		stringBuilder.WriteString(p.parseInlines(cell) + "</" + tag + ">\n")
+	}
+
+	stringBuilder.WriteString("</tr>\n")
+}
+
+// getHeadingId returns a unique identifier for a heading, like the identifiers generated by
+// Pandoc: Everything up to the first letter and all characters except letters, digits,
+// underscores, hyphens and periods are removed, spaces become hyphens, and duplicates get
+// a numeric suffix.
+// See: https://pandoc.org/MANUAL.html#extension-auto_identifiers
+func (p *parser) getHeadingId(text string) string {
+	var stringBuilder strings.Builder
+
+	for _, character := range strings.ToLower(strings.Join(strings.Fields(text), " ")) {
+		switch {
+		case stringBuilder.Len() == 0 && !unicode.IsLetter(character):
+			continue
+		case unicode.IsLetter(character) || unicode.IsDigit(character) || strings.ContainsRune("_-.", character):
+			stringBuilder.WriteRune(character)
+		case character == ' ':
+			stringBuilder.WriteRune('-')
+		}
+	}
+
+	id := stringBuilder.String()
+	if id == "" {
+		id = "section"
+	}
+
+	count := p.ids[id]
+	p.ids[id]++
+
+	if count > 0 {
+		return fmt.Sprintf("%s-%d", id, count)
+	}
+
+	return id
+}
+
+type frontMatter struct {
+	keys   []string
+	values map[string][]string
+}
+
+func (f *frontMatter) get(key string) string {
+	if values := f.values[key]; len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// parseFrontMatter parses a YAML front matter block with scalar values and lists of scalars,
+// and returns it together with the remaining document.
+func parseFrontMatter(source string) (*frontMatter, string) {
+	metaData := &frontMatter{values: make(map[string][]string)}
+
+	source = strings.TrimPrefix(source, "\uFEFF")
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+
+	matches := frontMatterRegexp.FindStringSubmatch(source)
+	if matches == nil {
+		return metaData, source
+	}
+
+	var key string
+
+	for _, line := range strings.Split(matches[1], "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// list item of the previous key, e.g. "  - Jane Doe"
+		if strings.HasPrefix(trimmed, "- ") && key != "" {
+			metaData.values[key] = append(metaData.values[key], unquote(trimmed[2:]))
+			continue
+		}
+
+		name, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			continue
+		}
+
+		key = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+
+		if _, exists := metaData.values[key]; !exists {
+			metaData.keys = append(metaData.keys, key)
+			metaData.values[key] = nil
+		}
+
+		// inline list, e.g. "keywords: [go, books]"
+		if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					metaData.values[key] = append(metaData.values[key], unquote(item))
+				}
+			}
+			continue
+		}
+
+		if value != "" {
+			metaData.values[key] = append(metaData.values[key], unquote(value))
+		}
+	}
+
+	return metaData, source[len(matches[0]):]
+}
+
+func unquote(value string) string {
+	value = strings.TrimSpace(value)
+
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+
+	return value
+}
+
+var headingRegexp = regexp.MustCompile(`<h[1-6][^>]*>(.*?)</h[1-6]>`)
+
+func getFirstHeading(content string) string {
+	matches := headingRegexp.FindStringSubmatch(content)
+	if matches == nil {
+		return ""
+	}
+
+	return html.UnescapeString(stripTags(matches[1]))
+}
@@ -0,0 +1,267 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package markdown
+
+import (
+	"testing"
+)
+
+// The examples are taken from the CommonMark and the GitHub Flavored Markdown specifications,
+// see https://spec.commonmark.org and https://github.github.com/gfm. Unlike the specifications,
+// headings get an id from their text.
+func TestHtml(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		expected string
+	}{
+		// headings
+		{
+			name:     "ATX headings",
+			markdown: "# foo\n## foo *bar*\n###### six\n####### seven\n",
+			expected: "<h1 id=\"foo\">foo</h1>\n<h2 id=\"foo-bar\">foo <em>bar</em></h2>\n<h6 id=\"six\">six</h6>\n<p>####### seven</p>\n",
+		},
+		{
+			name:     "setext headings",
+			markdown: "Foo bar\n===\n\nBaz\n---\n",
+			expected: "<h1 id=\"foo-bar\">Foo bar</h1>\n<h2 id=\"baz\">Baz</h2>\n",
+		},
+		{
+			name:     "setext heading before paragraph",
+			markdown: "foo\n---\nbar\n",
+			expected: "<h2 id=\"foo\">foo</h2>\n<p>bar</p>\n",
+		},
+		{
+			name:     "duplicate heading ids",
+			markdown: "# Title\n# Title\n",
+			expected: "<h1 id=\"title\">Title</h1>\n<h1 id=\"title-1\">Title</h1>\n",
+		},
+
+		// leaf blocks
+		{
+			name:     "thematic breaks",
+			markdown: "***\n---\n",
+			expected: "<hr />\n<hr />\n",
+		},
+		{
+			name:     "indented code block",
+			markdown: "    code\n    more\n",
+			expected: "<pre><code>code\nmore\n</code></pre>\n",
+		},
+		{
+			name:     "fenced code block with info string",
+			markdown: "```go\nfunc() {}\n<a>\n```\n",
+			expected: "<pre><code class=\"language-go\">func() {}\n&lt;a&gt;\n</code></pre>\n",
+		},
+		{
+			name:     "fenced code block with other fence",
+			markdown: "~~~\naaa\n```\n~~~\n",
+			expected: "<pre><code>aaa\n```\n</code></pre>\n",
+		},
+		{
+			name:     "fenced code block with longer closing fence",
+			markdown: "````\naaa\n```\n``````\n",
+			expected: "<pre><code>aaa\n```\n</code></pre>\n",
+		},
+		{
+			name:     "unclosed fenced code block",
+			markdown: "```\naaa\n",
+			expected: "<pre><code>aaa\n</code></pre>\n",
+		},
+		{
+			name:     "fenced code block with blank lines",
+			markdown: "```\n\n  \n```\n",
+			expected: "<pre><code>\n  \n</code></pre>\n",
+		},
+		{
+			name:     "HTML blocks",
+			markdown: "<div>\n*hi*\n</div>\n\n<!-- c -->\n\nx\n",
+			expected: "<div>\n*hi*\n</div>\n<!-- c -->\n<p>x</p>\n",
+		},
+
+		// container blocks
+		{
+			name:     "block quote",
+			markdown: "> quote\n> more\n",
+			expected: "<blockquote>\n<p>quote\nmore</p>\n</blockquote>\n",
+		},
+		{
+			name:     "block quote with blocks",
+			markdown: "> # h\n> - a\n",
+			expected: "<blockquote>\n<h1 id=\"h\">h</h1>\n<ul>\n<li>a</li>\n</ul>\n</blockquote>\n",
+		},
+		{
+			name:     "tight lists",
+			markdown: "- one\n- two\n\n1. a\n2. b\n",
+			expected: "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n<ol>\n<li>a</li>\n<li>b</li>\n</ol>\n",
+		},
+		{
+			name:     "loose list",
+			markdown: "- a\n\n- b\n",
+			expected: "<ul>\n<li>\n<p>a</p>\n</li>\n<li>\n<p>b</p>\n</li>\n</ul>\n",
+		},
+		{
+			name:     "ordered list with start number",
+			markdown: "3. a\n4. b\n",
+			expected: "<ol start=\"3\">\n<li>a</li>\n<li>b</li>\n</ol>\n",
+		},
+		{
+			name:     "list item with paragraphs",
+			markdown: "1. a\n\n   b\n",
+			expected: "<ol>\n<li>\n<p>a</p>\n<p>b</p>\n</li>\n</ol>\n",
+		},
+		{
+			name:     "nested list",
+			markdown: "- a\n  - b\n",
+			expected: "<ul>\n<li>a\n<ul>\n<li>b</li>\n</ul>\n</li>\n</ul>\n",
+		},
+		{
+			name:     "list interrupted by thematic break",
+			markdown: "- foo\n***\n- bar\n",
+			expected: "<ul>\n<li>foo</li>\n</ul>\n<hr />\n<ul>\n<li>bar</li>\n</ul>\n",
+		},
+
+		// inlines
+		{
+			name:     "emphasis",
+			markdown: "*foo* **bar** ***baz*** _a_ __b__\n",
+			expected: "<p><em>foo</em> <strong>bar</strong> <em><strong>baz</strong></em> <em>a</em> <strong>b</strong></p>\n",
+		},
+		{
+			name:     "intraword emphasis",
+			markdown: "foo*bar*\n",
+			expected: "<p>foo<em>bar</em></p>\n",
+		},
+		{
+			name:     "no emphasis at whitespace",
+			markdown: "a * foo bar*\n\n*foo bar *\n",
+			expected: "<p>a * foo bar*</p>\n<p>*foo bar *</p>\n",
+		},
+		{
+			name:     "unbalanced emphasis",
+			markdown: "**foo*\n",
+			expected: "<p>*<em>foo</em></p>\n",
+		},
+		{
+			name:     "code spans",
+			markdown: "`foo` `` a`b `` `<a>`\n",
+			expected: "<p><code>foo</code> <code>a`b</code> <code>&lt;a&gt;</code></p>\n",
+		},
+		{
+			name:     "backslash escapes",
+			markdown: "a\\*b \\<br/> not a tag\n",
+			expected: "<p>a*b &lt;br/&gt; not a tag</p>\n",
+		},
+		{
+			name:     "hard line breaks",
+			markdown: "a  \nb\\\nc\n",
+			expected: "<p>a<br />\nb<br />\nc</p>\n",
+		},
+		{
+			name:     "inline link and image",
+			markdown: "[link](/uri \"title\") ![img](/a.png) [a](<b c>)\n",
+			expected: "<p><a href=\"/uri\" title=\"title\">link</a> <img src=\"/a.png\" alt=\"img\" /> <a href=\"b%20c\">a</a></p>\n",
+		},
+		{
+			name:     "reference links",
+			markdown: "[foo] [Foo][BAR]\n\n[foo]: /url \"title\"\n[bar]: /other\n",
+			expected: "<p><a href=\"/url\" title=\"title\">foo</a> <a href=\"/other\">Foo</a></p>\n",
+		},
+		{
+			name:     "reference with empty unclosed destination",
+			markdown: "[0]: <\n",
+			expected: "<p>[0]: &lt;</p>\n",
+		},
+		{
+			name:     "reference with unclosed destination",
+			markdown: "[a]: <abc\n\n[a]\n",
+			expected: "<p>[a]: &lt;abc</p>\n<p>[a]</p>\n",
+		},
+		{
+			name:     "autolink",
+			markdown: "<http://foo.bar.baz>\n",
+			expected: "<p><a href=\"http://foo.bar.baz\">http://foo.bar.baz</a></p>\n",
+		},
+
+		// GitHub Flavored Markdown extensions
+		{
+			name:     "table",
+			markdown: "| foo | bar |\n| --- | --- |\n| baz | bim |\n",
+			expected: "<table>\n<thead>\n<tr>\n<th>foo</th>\n<th>bar</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>baz</td>\n<td>bim</td>\n</tr>\n</tbody>\n</table>\n",
+		},
+		{
+			name:     "table with alignment",
+			markdown: "| abc | defghi |\n:-: | -----------:\nbar | baz\n",
+			expected: "<table>\n<thead>\n<tr>\n<th align=\"center\">abc</th>\n<th align=\"right\">defghi</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td align=\"center\">bar</td>\n<td align=\"right\">baz</td>\n</tr>\n</tbody>\n</table>\n",
+		},
+		{
+			name:     "table with escaped pipes",
+			markdown: "| f\\|oo  |\n| ------ |\n| b `\\|` az |\n| b **\\|** im |\n",
+			expected: "<table>\n<thead>\n<tr>\n<th>f|oo</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>b <code>|</code> az</td>\n</tr>\n<tr>\n<td>b <strong>|</strong> im</td>\n</tr>\n</tbody>\n</table>\n",
+		},
+		{
+			name:     "table ended by block quote",
+			markdown: "| abc | def |\n| --- | --- |\n| bar | baz |\n> bar\n",
+			expected: "<table>\n<thead>\n<tr>\n<th>abc</th>\n<th>def</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>bar</td>\n<td>baz</td>\n</tr>\n</tbody>\n</table>\n<blockquote>\n<p>bar</p>\n</blockquote>\n",
+		},
+		{
+			name:     "table without body",
+			markdown: "| a |\n| - |\n",
+			expected: "<table>\n<thead>\n<tr>\n<th>a</th>\n</tr>\n</thead>\n</table>\n",
+		},
+		{
+			name:     "no table with mismatching delimiter row",
+			markdown: "| abc | def |\n| --- |\n| bar |\n",
+			expected: "<p>| abc | def |\n| --- |\n| bar |</p>\n",
+		},
+		{
+			name:     "task list",
+			markdown: "- [ ] foo\n- [x] bar\n",
+			expected: "<ul>\n<li><input type=\"checkbox\" disabled=\"\" /> foo</li>\n<li><input type=\"checkbox\" checked=\"\" disabled=\"\" /> bar</li>\n</ul>\n",
+		},
+		{
+			name:     "strikethrough",
+			markdown: "~~Hi~~ Hello, ~there~ world!\n",
+			expected: "<p><del>Hi</del> Hello, <del>there</del> world!</p>\n",
+		},
+		{
+			name:     "extended www autolink",
+			markdown: "www.commonmark.org/help\n",
+			expected: "<p><a href=\"http://www.commonmark.org/help\">www.commonmark.org/help</a></p>\n",
+		},
+		{
+			name:     "extended autolink with trailing punctuation",
+			markdown: "Visit https://example.com/a?b=c. Now www.commonmark.org/a.b.\n",
+			expected: "<p>Visit <a href=\"https://example.com/a?b=c\">https://example.com/a?b=c</a>. Now <a href=\"http://www.commonmark.org/a.b\">www.commonmark.org/a.b</a>.</p>\n",
+		},
+		{
+			name:     "extended autolink with parentheses",
+			markdown: "(www.google.com/search?q=Markup+(business))\n",
+			expected: "<p>(<a href=\"http://www.google.com/search?q=Markup+(business)\">www.google.com/search?q=Markup+(business)</a>)</p>\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := string(Html([]byte(test.markdown)))
+			if result != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestDocument(t *testing.T) {
+	document := "---\ntitle: My Book\nauthor: Jane\nlang: de\n---\n# One\n"
+	expected := "<!DOCTYPE html>\n<html lang=\"de\">\n<head>\n<meta charset=\"utf-8\" />\n<title>My Book</title>\n" +
+		"<meta name=\"author\" content=\"Jane\" />\n</head>\n<body>\n<h1 id=\"one\">One</h1>\n</body>\n</html>\n"
+
+	result := string(Document([]byte(document)))
+	if result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
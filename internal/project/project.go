@@ -0,0 +1,250 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+// Package project reads the project file of a book, which holds the settings otherwise
+// passed as command-line flags, and settings without flags, e.g. format-specific options.
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"stefanco.de/bookprint/internal/util/fs"
+	"stefanco.de/bookprint/internal/util/toml"
+)
+
+const (
+	JsonFileName = "bookprint.json"
+	TomlFileName = "bookprint.toml"
+)
+
+// Project holds the settings of a project file. Empty values are not set.
+// Paths are relative to the directory of the project file.
+type Project struct {
+	Name         string // file name of the project file
+	Inputs       []string
+	Languages    map[string][]string // input files of a multilingual book, key: language, e.g. "de"
+	InputFormat  string
+	OutputDir    string
+	TemplateDir  string
+	StaticDir    string
+	Formats      []string
+	SplitLevel   int
+	Paths        string
+	Layout       string
+	Numbering    *Numbering
+	Strict       bool
+	MetaData     MetaData
+	Options      map[string]map[string]string // format-specific options, key: format, value: options by name
+	Translations map[string]map[string]string // texts of the templates, key: language, value: texts by key
+}
+
+// MetaData overrides the meta data of the book.
+type MetaData struct {
+	Title        string        `json:"title"`
+	Author       string        `json:"author"`
+	Contributors []Contributor `json:"contributors"`
+	Date         string        `json:"date"`
+	Published    string        `json:"published"`
+	Modified     string        `json:"modified"`
+	Description  string        `json:"description"`
+	Keywords     []string      `json:"keywords"`
+	Subjects     []string      `json:"subjects"`
+	Language     string        `json:"language"`
+	Publisher    string        `json:"publisher"`
+	Rights       string        `json:"rights"`
+	Identifier   string        `json:"identifier"`
+	ISBN         string        `json:"isbn"`
+	Series       string        `json:"series"`
+	SeriesIndex  string        `json:"series-index"`
+	Cover        string        `json:"cover"`
+}
+
+// Contributor is a contributor of the book, see book.Contributor.
+type Contributor struct {
+	Name        string `json:"name"`
+	Role        string `json:"role"`
+	SortName    string `json:"sort-name"`
+	Affiliation string `json:"affiliation"`
+}
+
+// Numbering configures the prefixes of the headings, see book.Numbering.
+type Numbering struct {
+	Depth    int      `json:"depth"`
+	Schemes  []string `json:"schemes"`
+	Appendix string   `json:"appendix"`
+}
+
+// file is the structure of a project file. The keys match the long names of the
+// command-line flags, e.g. "output-dir" for "--output-dir".
+type file struct {
+	Inputs       []string                     `json:"inputs"`
+	Languages    map[string][]string          `json:"languages"`
+	InputFormat  string                       `json:"input-format"`
+	OutputDir    string                       `json:"output-dir"`
+	TemplateDir  string                       `json:"template-dir"`
+	StaticDir    string                       `json:"static-dir"`
+	Formats      []string                     `json:"format"`
+	SplitLevel   int                          `json:"split-level"`
+	Paths        string                       `json:"paths"`
+	Layout       string                       `json:"layout"`
+	Numbering    *Numbering                   `json:"numbering"`
+	Strict       bool                         `json:"strict"`
+	MetaData     MetaData                     `json:"metadata"`
+	Options      map[string]map[string]any    `json:"options"`
+	Translations map[string]map[string]string `json:"translations"`
+}
+
+// Find returns the name of the project file in the given directory, or an empty string
+// if the directory contains no project file.
+func Find(directory string) (string, error) {
+	var names []string
+
+	for _, name := range []string{JsonFileName, TomlFileName} {
+		if fs.ExistFile(filepath.Join(directory, name)) {
+			names = append(names, filepath.Join(directory, name))
+		}
+	}
+
+	if len(names) > 1 {
+		return "", fmt.Errorf("found both '%s' and '%s', only one project file is allowed", names[0], names[1])
+	}
+
+	if len(names) == 0 {
+		return "", nil
+	}
+
+	return names[0], nil
+}
+
+// New reads a JSON or TOML project file, depending on the file extension.
+// Unknown keys are reported as errors.
+func New(name string) (*Project, error) {
+	var content file
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		err = decode(data, &content)
+	case ".toml":
+		var document map[string]any
+
+		document, err = toml.Parse(data)
+		if err == nil {
+			data, err = json.Marshal(document)
+		}
+		if err == nil {
+			err = decode(data, &content)
+		}
+	default:
+		return nil, fmt.Errorf("project file '%s' must be a JSON or TOML file", name)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid project file '%s': %w", name, err)
+	}
+
+	options, err := getOptions(content.Options)
+	if err != nil {
+		return nil, fmt.Errorf("invalid project file '%s': %w", name, err)
+	}
+
+	if len(content.Inputs) > 0 && len(content.Languages) > 0 {
+		return nil, fmt.Errorf("invalid project file '%s': 'inputs' and 'languages' cannot be used together", name)
+	}
+
+	directory := filepath.Dir(name)
+
+	var inputs []string
+	for _, input := range content.Inputs {
+		inputs = append(inputs, getPath(directory, input))
+	}
+
+	var languages map[string][]string
+	if len(content.Languages) > 0 {
+		languages = make(map[string][]string)
+	}
+
+	for language, languageInputs := range content.Languages {
+		for _, input := range languageInputs {
+			languages[language] = append(languages[language], getPath(directory, input))
+		}
+	}
+
+	return &Project{
+		Name:         name,
+		Inputs:       inputs,
+		Languages:    languages,
+		InputFormat:  content.InputFormat,
+		OutputDir:    getPath(directory, content.OutputDir),
+		TemplateDir:  getPath(directory, content.TemplateDir),
+		StaticDir:    getPath(directory, content.StaticDir),
+		Formats:      content.Formats,
+		SplitLevel:   content.SplitLevel,
+		Paths:        content.Paths,
+		Layout:       content.Layout,
+		Numbering:    content.Numbering,
+		Strict:       content.Strict,
+		MetaData:     content.MetaData,
+		Options:      options,
+		Translations: content.Translations,
+	}, nil
+}
+
+func decode(data []byte, content *file) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	return decoder.Decode(content)
+}
+
+// getOptions converts the option values to strings, e.g. true to "true".
+func getOptions(options map[string]map[string]any) (map[string]map[string]string, error) {
+	result := make(map[string]map[string]string)
+
+	var formats []string
+	for format := range options {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+
+	for _, format := range formats {
+		result[format] = make(map[string]string)
+
+		for name, value := range options[format] {
+			switch value := value.(type) {
+			case string, bool:
+				result[format][name] = fmt.Sprint(value)
+			case float64:
+				// without exponent, e.g. "1000000" instead of "1e+06"
+				result[format][name] = strconv.FormatFloat(value, 'f', -1, 64)
+			default:
+				return nil, fmt.Errorf("option '%s' of format '%s' must be a string, number or boolean", name, format)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// getPath returns the path relative to the directory of the project file.
+func getPath(directory string, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(directory, filepath.FromSlash(path))
+}
@@ -0,0 +1,34 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOptions(t *testing.T) {
+	name := filepath.Join(t.TempDir(), TomlFileName)
+	document := "[options.html]\nsearch = false\nlimit = 1000000\nratio = 1.5\nlarge = 1e21\ntheme = \"dark\"\n"
+
+	err := os.WriteFile(name, []byte(document), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	project, err := New(name)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := map[string]string{"search": "false", "limit": "1000000", "ratio": "1.5", "large": "1000000000000000000000", "theme": "dark"}
+	if !reflect.DeepEqual(project.Options["html"], expected) {
+		t.Errorf("expected %v, got %v", expected, project.Options["html"])
+	}
+}
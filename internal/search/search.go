@@ -0,0 +1,158 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+// Package search creates a full-text search index of a book, so that a script on the
+// website can search the book by looking up terms, without tokenizing the pages itself.
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"stefanco.de/bookprint/internal/book"
+	"stefanco.de/bookprint/internal/util/parsetree"
+)
+
+// FileName is the name of the search index file in the output directory.
+const FileName = "search-index.json"
+
+// titleWeight is the weight of terms in the title compared to terms in the text.
+const titleWeight = 10
+
+// Index is the search index of a book.
+type Index struct {
+	Pages []*Page          `json:"pages"`
+	Terms map[string][]int `json:"terms,omitempty"` // inverted index: key: term, value: indices of the pages, most relevant first
+}
+
+// Page is a page of the search index. Without inverted index, the terms of a page are listed
+// with the page.
+type Page struct {
+	Path     string     `json:"path"`
+	Title    string     `json:"title"`
+	Prefix   string     `json:"prefix"`
+	Text     string     `json:"text"`
+	Terms    []string   `json:"terms,omitempty"`
+	Sections []*Section `json:"sections"`
+}
+
+// Section is a heading within a page, which can be linked to by its id.
+type Section struct {
+	Id     string `json:"id"`
+	Title  string `json:"title"`
+	Prefix string `json:"prefix"`
+}
+
+// New creates the search index of a book. With inverted, the index maps every term to
+// the pages containing it, instead of listing the terms of every page.
+func New(b *book.Book, inverted bool) (*Index, error) {
+	index := &Index{Pages: []*Page{}}
+
+	if inverted {
+		index.Terms = make(map[string][]int)
+	}
+
+	weights := make(map[string]map[int]int) // key: term, value: weight by page index
+
+	for pageIndex, page := range b.Pages {
+		text, err := getText(page)
+		if err != nil {
+			return nil, err
+		}
+
+		searchPage := &Page{
+			Path:     page.Path,
+			Title:    page.Title.Text,
+			Prefix:   page.Title.Prefix,
+			Text:     text,
+			Sections: []*Section{},
+		}
+
+		titleTerms := Tokenize(page.Title.Text)
+		for _, section := range page.Sections {
+			titleTerms = append(titleTerms, Tokenize(section.Title.Text)...)
+
+			searchPage.Sections = append(searchPage.Sections, &Section{
+				Id:     section.Id,
+				Title:  section.Title.Text,
+				Prefix: section.Title.Prefix,
+			})
+		}
+
+		pageWeights := make(map[string]int)
+		for _, term := range titleTerms {
+			pageWeights[term] += titleWeight
+		}
+		for _, term := range Tokenize(text) {
+			pageWeights[term]++
+		}
+
+		for term, weight := range pageWeights {
+			if inverted {
+				if weights[term] == nil {
+					weights[term] = make(map[int]int)
+				}
+				weights[term][pageIndex] = weight
+			} else {
+				searchPage.Terms = append(searchPage.Terms, term)
+			}
+		}
+
+		sort.Strings(searchPage.Terms)
+
+		index.Pages = append(index.Pages, searchPage)
+	}
+
+	for term, pageWeights := range weights {
+		var pageIndices []int
+		for pageIndex := range pageWeights {
+			pageIndices = append(pageIndices, pageIndex)
+		}
+
+		sort.Slice(pageIndices, func(i, j int) bool {
+			a, b := pageIndices[i], pageIndices[j]
+			if pageWeights[a] != pageWeights[b] {
+				return pageWeights[a] > pageWeights[b]
+			}
+
+			return a < b
+		})
+
+		index.Terms[term] = pageIndices
+	}
+
+	return index, nil
+}
+
+// Tokenize splits a text into lower case terms, skipping stop words and single characters.
+func Tokenize(text string) []string {
+	var terms []string
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(character rune) bool {
+		return !unicode.IsLetter(character) && !unicode.IsDigit(character)
+	})
+
+	for _, word := range words {
+		if len([]rune(word)) < 2 || stopWords[word] {
+			continue
+		}
+
+		terms = append(terms, word)
+	}
+
+	return terms
+}
+
+// getText returns the plain text of the content of a page, with whitespace collapsed.
+func getText(page *book.Page) (string, error) {
+	tree, err := parsetree.New(string(page.Content.Html))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(strings.Fields(parsetree.Text(parsetree.Body(tree))), " "), nil
+}
@@ -0,0 +1,37 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package search
+
+// stopWords are frequent English and German words, which are not worth searching for.
+var stopWords = toSet(
+	// English
+	"a", "about", "after", "all", "also", "an", "and", "any", "are", "as", "at", "be", "been",
+	"but", "by", "can", "could", "did", "do", "does", "for", "from", "had", "has", "have", "he",
+	"her", "his", "how", "if", "in", "into", "is", "it", "its", "may", "more", "most", "no",
+	"not", "of", "on", "or", "other", "our", "she", "should", "so", "some", "such", "than",
+	"that", "the", "their", "them", "then", "there", "these", "they", "this", "those", "to",
+	"was", "we", "were", "what", "when", "where", "which", "while", "who", "will", "with",
+	"would", "you", "your",
+
+	// German
+	"aber", "als", "am", "an", "auch", "auf", "aus", "bei", "bin", "bis", "da", "damit", "das",
+	"dass", "dem", "den", "der", "des", "die", "dies", "diese", "dieser", "dieses", "doch",
+	"du", "durch", "ein", "eine", "einem", "einen", "einer", "eines", "er", "es", "für", "hat",
+	"hatte", "ich", "ihr", "im", "in", "ist", "kann", "mit", "nach", "nicht", "noch", "nur",
+	"ob", "oder", "sich", "sie", "sind", "so", "über", "um", "und", "uns", "unter", "vom",
+	"von", "vor", "war", "was", "wenn", "werden", "wie", "wir", "wird", "zu", "zum", "zur",
+)
+
+func toSet(words ...string) map[string]bool {
+	set := make(map[string]bool)
+
+	for _, word := range words {
+		set[word] = true
+	}
+
+	return set
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+// Package serve provides a preview server for the files of a directory, which reloads
+// its HTML pages in the browser, whenever Reload is called.
+package serve
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventsPath is the path of the server-sent events endpoint, which sends a "reload"
+// message to all pages after a rebuild.
+const EventsPath = "/_bookprint/events"
+
+// reloadScript is injected into every HTML page to reload it on a "reload" message.
+const reloadScript = `<script>new EventSource("` + EventsPath + `").onmessage = function () { location.reload(); };</script>`
+
+// Server serves the files of a directory and injects the reload script into HTML pages.
+type Server struct {
+	directory string
+	files     http.Handler
+
+	mutex   sync.Mutex
+	clients map[chan struct{}]bool
+}
+
+// New returns a server for the files of the given directory.
+func New(directory string) *Server {
+	return &Server{
+		directory: directory,
+		files:     http.FileServer(http.Dir(directory)),
+		clients:   make(map[chan struct{}]bool),
+	}
+}
+
+// Reload sends a "reload" message to all connected pages.
+func (s *Server) Reload() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for client := range s.clients {
+		select {
+		case client <- struct{}{}:
+		default: // a reload is already pending
+		}
+	}
+}
+
+func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.URL.Path == EventsPath {
+		s.serveEvents(writer, request)
+		return
+	}
+
+	if name := s.getPageFileName(request.URL.Path); name != "" {
+		s.servePage(writer, request, name)
+		return
+	}
+
+	s.files.ServeHTTP(writer, request)
+}
+
+// serveEvents keeps the connection open and sends a "reload" message on every reload.
+func (s *Server) serveEvents(writer http.ResponseWriter, request *http.Request) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		http.Error(writer, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+
+	client := make(chan struct{}, 1)
+
+	s.mutex.Lock()
+	s.clients[client] = true
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		delete(s.clients, client)
+		s.mutex.Unlock()
+	}()
+
+	_, _ = fmt.Fprint(writer, ": connected\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-client:
+			_, _ = fmt.Fprint(writer, "data: reload\n\n")
+			flusher.Flush()
+		case <-request.Context().Done():
+			return
+		}
+	}
+}
+
+// servePage serves an HTML page with the injected reload script.
+func (s *Server) servePage(writer http.ResponseWriter, request *http.Request, name string) {
+	content, err := os.ReadFile(name)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Cache-Control", "no-cache")
+
+	http.ServeContent(writer, request, name, time.Time{}, bytes.NewReader(injectScript(content)))
+}
+
+// getPageFileName returns the file name of the HTML page with the given URL path, i.e. of an
+// HTML file or of the "index.html" file of a directory, or an empty file name for other files.
+func (s *Server) getPageFileName(urlPath string) string {
+	name := filepath.Join(s.directory, filepath.FromSlash(path.Clean("/"+urlPath)))
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return ""
+	}
+
+	if info.IsDir() {
+		// Without trailing slash, the file server redirects to the directory URL.
+		if !strings.HasSuffix(urlPath, "/") {
+			return ""
+		}
+
+		name = filepath.Join(name, "index.html")
+
+		info, err = os.Stat(name)
+		if err != nil || info.IsDir() {
+			return ""
+		}
+	}
+
+	if filepath.Ext(name) != ".html" {
+		return ""
+	}
+
+	return name
+}
+
+// injectScript inserts the reload script before the closing body tag of a page,
+// or at its end, if there is none.
+func injectScript(content []byte) []byte {
+	index := bytes.LastIndex(bytes.ToLower(content), []byte("</body>"))
+	if index < 0 {
+		index = len(content)
+	}
+
+	injected := make([]byte, 0, len(content)+len(reloadScript))
+	injected = append(injected, content[:index]...)
+	injected = append(injected, reloadScript...)
+	injected = append(injected, content[index:]...)
+
+	return injected
+}
@@ -0,0 +1,276 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package source
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"stefanco.de/bookprint/internal/util/parsetree"
+)
+
+type document struct {
+	file    *File
+	tree    *html.Node
+	body    *html.Node
+	heading string            // text of the first heading, which becomes the title of the first page
+	anchor  *html.Node        // first heading, whose id refers to the file, if it has one
+	ids     map[string]string // renamed element ids: key: original id, value: unique id
+}
+
+// Merge concatenates the bodies of multiple HTML documents into a single HTML document.
+// The head is taken from the first document, with the title, author and date of the
+// manifest taking precedence.
+//
+// Element ids are made unique across all documents, and links between the documents are
+// rewritten, so that they can be resolved as cross-references: A link to another file
+// refers to the id of its first heading, or else to its title, and a link to an element
+// of another file becomes a fragment link, e.g. "chapter2.html#setup" becomes "#setup".
+// See book.ResolveCrossReferences.
+func Merge(files []*File, manifest *Manifest) ([]byte, error) {
+	var documents []*document
+
+	if len(files) == 0 {
+		return nil, errors.New("no files to merge")
+	}
+
+	for _, file := range files {
+		tree, err := parsetree.New(string(file.Html))
+		if err != nil {
+			return nil, err
+		}
+
+		body := parsetree.Body(tree)
+		if !parsetree.IsBody(body) {
+			return nil, fmt.Errorf("file '%s' contains no 'body' element", file.Name)
+		}
+
+		var heading string
+		var anchor *html.Node
+		if headings := parsetree.Headings(body); len(headings) > 0 {
+			heading = strings.Join(strings.Fields(parsetree.Text(headings[0])), " ")
+			anchor = headings[0]
+		}
+
+		documents = append(documents, &document{
+			file:    file,
+			tree:    tree,
+			body:    body,
+			heading: heading,
+			anchor:  anchor,
+			ids:     make(map[string]string),
+		})
+	}
+
+	setUniqueIds(documents)
+
+	for _, current := range documents {
+		rewriteLinks(current, documents)
+	}
+
+	first := documents[0]
+
+	if manifest != nil {
+		head := parsetree.Head(first.tree)
+		if !parsetree.IsHead(head) {
+			return nil, fmt.Errorf("file '%s' contains no 'head' element", first.file.Name)
+		}
+
+		setMetaData(head, manifest)
+	}
+
+	// move the content of all other bodies into the first body
+	for _, current := range documents[1:] {
+		for _, child := range parsetree.Children(current.body) {
+			current.body.RemoveChild(child)
+			first.body.AppendChild(child)
+		}
+	}
+
+	var buffer bytes.Buffer
+
+	err := html.Render(&buffer, first.tree)
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// setUniqueIds renames element ids, which were already used in a previous document. Ids used
+// more than once within the same document are renamed alike, if at all, so that links within
+// the document still refer to the first element with the id.
+func setUniqueIds(documents []*document) {
+	used := make(map[string]bool) // ids of the previous documents
+
+	for _, current := range documents {
+		elements := getElementsWithId(current.body)
+
+		taken := make(map[string]bool) // ids of the current document, including renamed ones
+		for _, element := range elements {
+			taken[parsetree.AttributeMap(element)["id"]] = true
+		}
+
+		for _, element := range elements {
+			for index, attribute := range element.Attr {
+				if attribute.Key != "id" {
+					continue
+				}
+
+				id := attribute.Val
+
+				unique, ok := current.ids[id]
+				if !ok {
+					unique = id
+
+					for counter := 1; used[unique] || (unique != id && taken[unique]); counter++ {
+						unique = fmt.Sprintf("%s-%d", id, counter)
+					}
+
+					if unique != id {
+						current.ids[id] = unique
+						taken[unique] = true
+					}
+				}
+
+				element.Attr[index].Val = unique
+			}
+		}
+
+		for id := range taken {
+			used[id] = true
+		}
+	}
+}
+
+func rewriteLinks(current *document, documents []*document) {
+	for _, link := range parsetree.ElementsByTagName(current.body, "a") {
+		for index, attribute := range link.Attr {
+			if attribute.Key != "href" {
+				continue
+			}
+
+			reference, err := url.Parse(attribute.Val)
+			if err != nil || reference.Scheme != "" || reference.Host != "" {
+				continue
+			}
+
+			// fragment link within the same file
+			if reference.Path == "" {
+				if unique, ok := current.ids[reference.Fragment]; ok {
+					link.Attr[index].Val = "#" + unique
+				}
+				continue
+			}
+
+			target := getLinkedDocument(current, reference.Path, documents)
+			if target == nil {
+				continue
+			}
+
+			if reference.Fragment != "" {
+				fragment := reference.Fragment
+				if unique, ok := target.ids[fragment]; ok {
+					fragment = unique
+				}
+
+				link.Attr[index].Val = "#" + fragment
+				continue
+			}
+
+			if id := parsetree.AttributeMap(target.anchor)["id"]; id != "" {
+				// fragment link to the heading, which refers to its page
+				link.Attr[index].Val = "#" + id
+			} else if target.heading != "" {
+				// cross-reference by title, which may be ambiguous
+				link.Attr[index].Val = url.QueryEscape(target.heading)
+			}
+		}
+	}
+}
+
+// getLinkedDocument returns the document whose file is referred to by the given path,
+// relative to the file of the current document, or nil.
+func getLinkedDocument(current *document, path string, documents []*document) *document {
+	linked := filepath.Clean(filepath.Join(filepath.Dir(current.file.Name), filepath.FromSlash(path)))
+
+	for _, candidate := range documents {
+		if filepath.Clean(candidate.file.Name) == linked {
+			return candidate
+		}
+	}
+
+	return nil
+}
+
+func getElementsWithId(node *html.Node) []*html.Node {
+	var elements []*html.Node
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if parsetree.IsElement(child) {
+			if _, ok := parsetree.AttributeMap(child)["id"]; ok {
+				elements = append(elements, child)
+			}
+		}
+
+		elements = append(elements, getElementsWithId(child)...)
+	}
+
+	return elements
+}
+
+// setMetaData replaces the title, author and date of the head with the values of the manifest.
+func setMetaData(head *html.Node, manifest *Manifest) {
+	if manifest.Title != "" {
+		titles := parsetree.ElementsByTagName(head, "title")
+
+		title := &html.Node{Type: html.ElementNode, Data: "title", DataAtom: atom.Title}
+		if len(titles) > 0 {
+			title = titles[0]
+			for child := title.FirstChild; child != nil; child = title.FirstChild {
+				title.RemoveChild(child)
+			}
+		} else {
+			head.AppendChild(title)
+		}
+
+		title.AppendChild(&html.Node{Type: html.TextNode, Data: manifest.Title})
+	}
+
+	if manifest.Author != "" {
+		setMeta(head, "author", manifest.Author)
+	}
+
+	if manifest.Date != "" {
+		setMeta(head, "dcterms.date", manifest.Date)
+	}
+}
+
+func setMeta(head *html.Node, name string, content string) {
+	for _, meta := range parsetree.ElementsByTagName(head, "meta") {
+		if parsetree.AttributeMap(meta)["name"] == name {
+			meta.Parent.RemoveChild(meta)
+		}
+	}
+
+	head.AppendChild(&html.Node{
+		Type:     html.ElementNode,
+		Data:     "meta",
+		DataAtom: atom.Meta,
+		Attr: []html.Attribute{
+			{Key: "name", Val: name},
+			{Key: "content", Val: content},
+		},
+	})
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package source
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeLinks(t *testing.T) {
+	files := []*File{
+		{Name: "chapters/1-intro.html", Html: []byte(`<h1 id="intro">Overview</h1>` +
+			`<a href="2-setup.html">file</a><a href="2-setup.html#install">element</a>` +
+			`<a href="2-setup.html#intro">renamed</a><a href="3-usage.html">title</a><a href="#intro">local</a>`)},
+		{Name: "chapters/2-setup.html", Html: []byte(`<h1 id="intro">Overview</h1><h2 id="install">Install</h2>` +
+			`<a href="#intro">local</a><a href="1-intro.html#intro">back</a><a href="https://example.org/a.html#b">external</a>`)},
+		{Name: "chapters/3-usage.html", Html: []byte(`<h1>Usage and more</h1>`)},
+	}
+
+	merged, err := Merge(files, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []string{
+		`<h1 id="intro">Overview</h1>`,
+		`<a href="#intro-1">file</a>`,
+		`<a href="#install">element</a>`,
+		`<a href="#intro-1">renamed</a>`,
+		`<a href="Usage+and+more">title</a>`,
+		`<a href="#intro">local</a>`,
+		`<h1 id="intro-1">Overview</h1>`,
+		`<a href="#intro-1">local</a>`,
+		`<a href="#intro">back</a>`,
+		`<a href="https://example.org/a.html#b">external</a>`,
+	}
+
+	for _, html := range expected {
+		if !strings.Contains(string(merged), html) {
+			t.Errorf("expected '%s' in merged document: %s", html, merged)
+		}
+	}
+}
+
+func TestMergeDuplicateIds(t *testing.T) {
+	files := []*File{
+		{Name: "1-intro.html", Html: []byte(`<h1 id="intro">Intro</h1><p id="note">first</p><p id="note">second</p><a href="#note">note</a>`)},
+		{Name: "2-setup.html", Html: []byte(`<h1 id="setup">Setup</h1><p id="intro">first</p><p id="intro">second</p>` +
+			`<a href="#intro">local</a><a href="1-intro.html#note">note</a>`)},
+	}
+
+	merged, err := Merge(files, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []string{
+		`<p id="note">first</p><p id="note">second</p><a href="#note">note</a>`,
+		`<p id="intro-1">first</p><p id="intro-1">second</p>`,
+		`<a href="#intro-1">local</a>`,
+		`<a href="#note">note</a>`,
+	}
+
+	for _, html := range expected {
+		if !strings.Contains(string(merged), html) {
+			t.Errorf("expected '%s' in merged document: %s", html, merged)
+		}
+	}
+}
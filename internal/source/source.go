@@ -0,0 +1,314 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package source
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"stefanco.de/bookprint/internal/markdown"
+	utilFs "stefanco.de/bookprint/internal/util/fs"
+)
+
+// ManifestFileName is the name of the manifest file, which determines the order of the
+// files in a directory and may provide the meta data of the book.
+const ManifestFileName = "manifest.json"
+
+// Manifest lists the files of a book in reading order. File names are relative to the
+// directory of the manifest and may contain glob patterns.
+type Manifest struct {
+	Title  string   `json:"title"`
+	Author string   `json:"author"`
+	Date   string   `json:"date"`
+	Files  []string `json:"files"`
+}
+
+// File is a single input file converted to an HTML document.
+type File struct {
+	Name string
+	Html []byte
+}
+
+// extensions are the file extensions considered when reading the files of a directory.
+var extensions = []string{".html", ".htm", ".xhtml", ".md", ".markdown", ".mdown", ".mkd"}
+
+// New reads the given files, directories and glob patterns, converts them to HTML and
+// concatenates them into a single HTML document. Without names, STDIN is read.
+func New(names []string, inputFormat string) ([]byte, error) {
+	if len(names) == 0 {
+		file, err := utilFs.StdinAll()
+		if err != nil {
+			return file, err
+		}
+
+		return convert(file, "", inputFormat)
+	}
+
+	fileNames, manifest, err := Files(names)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*File
+
+	for _, fileName := range fileNames {
+		file, err := os.ReadFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		html, err := convert(file, fileName, inputFormat)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, &File{Name: fileName, Html: html})
+	}
+
+	if len(files) == 1 && manifest == nil {
+		return files[0].Html, nil
+	}
+
+	return Merge(files, manifest)
+}
+
+// Files resolves the given files, directories and glob patterns into a list of files in
+// reading order. The files of a directory are ordered by the manifest of the directory, if
+// present, or else by natural sort order of their names, e.g. "2.md" before "10.md".
+// The returned manifest is the last manifest found, or nil.
+func Files(names []string) ([]string, *Manifest, error) {
+	var files []string
+	var manifest *Manifest
+
+	for _, name := range names {
+		switch {
+		case filepath.Base(name) == ManifestFileName && utilFs.ExistFile(name):
+			current, manifestFiles, err := readManifest(name)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			manifest = current
+			files = append(files, manifestFiles...)
+
+		case utilFs.ExistFile(name):
+			files = append(files, name)
+
+		case utilFs.ExistDir(name) && !strings.ContainsAny(name, "*?["):
+			manifestName := filepath.Join(name, ManifestFileName)
+
+			if utilFs.ExistFile(manifestName) {
+				current, manifestFiles, err := readManifest(manifestName)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				manifest = current
+				files = append(files, manifestFiles...)
+
+				continue
+			}
+
+			directoryFiles, err := getDirectoryFiles(name)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if len(directoryFiles) == 0 {
+				return nil, nil, fmt.Errorf("directory '%s' contains no HTML or Markdown files", name)
+			}
+
+			files = append(files, directoryFiles...)
+
+		case strings.ContainsAny(name, "*?["):
+			matches, err := filepath.Glob(name)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid pattern '%s': %w", name, err)
+			}
+
+			if len(matches) == 0 {
+				return nil, nil, fmt.Errorf("pattern '%s' matches no files", name)
+			}
+
+			sort.SliceStable(matches, func(i, j int) bool {
+				return naturalLess(matches[i], matches[j])
+			})
+
+			files = append(files, matches...)
+
+		default:
+			return nil, nil, fmt.Errorf("file '%s' does not exist", name)
+		}
+	}
+
+	return files, manifest, nil
+}
+
+// InputFormat returns the input format matching the extension of the file name.
+// Files without a known Markdown extension are read as HTML.
+func InputFormat(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".md", ".markdown", ".mdown", ".mkd":
+		return "markdown"
+	default:
+		return "html"
+	}
+}
+
+func convert(file []byte, name string, inputFormat string) ([]byte, error) {
+	if inputFormat == "" {
+		inputFormat = InputFormat(name)
+	}
+
+	switch inputFormat {
+	case "html":
+		return file, nil
+	case "markdown":
+		return markdown.Document(file), nil
+	default:
+		return nil, fmt.Errorf("unknown input format '%s', available input formats are: html, markdown", inputFormat)
+	}
+}
+
+func readManifest(name string) (*Manifest, []string, error) {
+	var manifest *Manifest
+	var files []string
+
+	file, err := os.ReadFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(file))
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(&manifest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid manifest '%s': %w", name, err)
+	}
+
+	directory := filepath.Dir(name)
+
+	for _, manifestFile := range manifest.Files {
+		pattern := filepath.Join(directory, filepath.FromSlash(manifestFile))
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil || len(matches) == 0 {
+			return nil, nil, fmt.Errorf("file '%s' listed in manifest '%s' does not exist", manifestFile, name)
+		}
+
+		sort.SliceStable(matches, func(i, j int) bool {
+			return naturalLess(matches[i], matches[j])
+		})
+
+		files = append(files, matches...)
+	}
+
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("manifest '%s' lists no files", name)
+	}
+
+	return manifest, files, nil
+}
+
+// getDirectoryFiles recursively returns all HTML and Markdown files of a directory in
+// natural sort order, skipping hidden files and directories.
+func getDirectoryFiles(directory string) ([]string, error) {
+	var files []string
+
+	err := filepath.WalkDir(directory, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if name != directory && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if entry.Type().IsRegular() && isSourceFile(name) {
+			files = append(files, name)
+		}
+
+		return nil
+	})
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return naturalLess(filepath.ToSlash(files[i]), filepath.ToSlash(files[j]))
+	})
+
+	return files, err
+}
+
+func isSourceFile(name string) bool {
+	extension := strings.ToLower(filepath.Ext(name))
+
+	for _, current := range extensions {
+		if extension == current {
+			return true
+		}
+	}
+
+	return false
+}
+
+// naturalLess compares two strings, treating sequences of digits as numbers,
+// so that "chapter2" sorts before "chapter10".
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		aIsDigit := unicode.IsDigit(rune(a[0]))
+		bIsDigit := unicode.IsDigit(rune(b[0]))
+
+		if aIsDigit && bIsDigit {
+			aNumber, aRest := splitNumber(a)
+			bNumber, bRest := splitNumber(b)
+
+			// Compare the numbers by their length first, ignoring leading zeros.
+			aTrimmed := strings.TrimLeft(aNumber, "0")
+			bTrimmed := strings.TrimLeft(bNumber, "0")
+
+			if len(aTrimmed) != len(bTrimmed) {
+				return len(aTrimmed) < len(bTrimmed)
+			}
+
+			if aTrimmed != bTrimmed {
+				return aTrimmed < bTrimmed
+			}
+
+			a, b = aRest, bRest
+			continue
+		}
+
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+
+		a, b = a[1:], b[1:]
+	}
+
+	return len(a) < len(b)
+}
+
+func splitNumber(text string) (string, string) {
+	index := 0
+
+	for index < len(text) && unicode.IsDigit(rune(text[index])) {
+		index++
+	}
+
+	return text[:index], text[index:]
+}
@@ -0,0 +1,58 @@
+// Searches the pages of the search index created by BookPrint, see "search-index.json".
+(function () {
+  const form = document.querySelector("form.search");
+  if (!form) {
+    return;
+  }
+
+  const input = form.querySelector("input");
+  const results = form.querySelector(".results");
+  const index = fetch(form.dataset.index).then((response) => response.json());
+
+  const tokenize = (text) => text.toLowerCase().split(/[^\p{L}\p{N}]+/u).filter((token) => token.length > 1);
+
+  const search = (index, tokens) => {
+    const scores = new Map();
+
+    for (const token of tokens) {
+      if (index.terms) {
+        // inverted index: term -> pages
+        for (const [term, pages] of Object.entries(index.terms)) {
+          if (term.startsWith(token)) {
+            pages.forEach((page, rank) => scores.set(page, (scores.get(page) || 0) + pages.length - rank));
+          }
+        }
+      } else {
+        index.pages.forEach((page, position) => {
+          const matches = (page.terms || []).filter((term) => term.startsWith(token)).length;
+          if (matches > 0) {
+            scores.set(position, (scores.get(position) || 0) + matches);
+          }
+        });
+      }
+    }
+
+    return [...scores.entries()].sort((a, b) => b[1] - a[1]).map(([position]) => index.pages[position]);
+  };
+
+  form.addEventListener("submit", (event) => event.preventDefault());
+
+  input.addEventListener("input", async () => {
+    const tokens = tokenize(input.value);
+    results.replaceChildren();
+
+    if (tokens.length === 0) {
+      return;
+    }
+
+    for (const page of search(await index, tokens).slice(0, 20)) {
+      const link = document.createElement("a");
+      link.href = page.path;
+      link.textContent = (page.prefix ? page.prefix + " " : "") + page.title;
+
+      const item = document.createElement("li");
+      item.append(link);
+      results.append(item);
+    }
+  });
+})();
@@ -0,0 +1,62 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+// Package theme provides the default theme, which is embedded into the binary and used
+// for the HTML output, if no template directory is given.
+package theme
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// Directories of the theme.
+const (
+	TemplateDir = "templates"
+	StaticDir   = "static"
+)
+
+//go:embed templates static
+var files embed.FS
+
+// Templates are the "index.html", "map.html" and "page.html" templates of the theme.
+var Templates = getSubFS(TemplateDir)
+
+// Static are the files of the theme, which are copied into the output directory,
+// e.g. "style.css".
+var Static = getSubFS(StaticDir)
+
+// Files returns the templates and static files of the theme, e.g. to write them to disk for
+// customization. The keys are slash-separated paths like "templates/page.html".
+func Files() (map[string][]byte, error) {
+	themeFiles := make(map[string][]byte)
+
+	err := fs.WalkDir(files, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		content, err := files.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		themeFiles[name] = content
+
+		return nil
+	})
+
+	return themeFiles, err
+}
+
+func getSubFS(directory string) fs.FS {
+	sub, err := fs.Sub(files, directory)
+	if err != nil {
+		panic(err) // only fails for invalid directory names
+	}
+
+	return sub
+}
@@ -309,3 +309,102 @@ func Html(nodes ...*html.Node) (template.HTML, error) {
 
 	return template.HTML(buffer.String()), nil
 }
+
+// Xhtml returns the serialized XHTML content of the given HTML nodes, i.e. well-formed XML
+// with self-closing void elements and escaped text and attribute values.
+func Xhtml(nodes ...*html.Node) (string, error) {
+	var stringBuilder strings.Builder
+
+	for _, node := range nodes {
+		if node != nil {
+			err := renderXhtml(&stringBuilder, node)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return stringBuilder.String(), nil
+}
+
+func renderXhtml(stringBuilder *strings.Builder, node *html.Node) error {
+	switch node.Type {
+	case html.TextNode:
+		stringBuilder.WriteString(html.EscapeString(node.Data))
+		return nil
+	case html.CommentNode:
+		// The sequence "--" is not allowed within XML comments.
+		stringBuilder.WriteString("<!--")
+		stringBuilder.WriteString(strings.ReplaceAll(node.Data, "--", "- -"))
+		stringBuilder.WriteString("-->")
+		return nil
+	case html.ElementNode:
+		stringBuilder.WriteString("<")
+		stringBuilder.WriteString(node.Data)
+
+		// Elements of another namespace than their parent, e.g. inline "svg" and "math", or HTML
+		// within "foreignObject", declare their namespace, unless the content already does.
+		if node.Parent != nil && node.Parent.Type == html.ElementNode && node.Parent.Namespace != node.Namespace && !hasAttribute(node, "xmlns") {
+			stringBuilder.WriteString(` xmlns="`)
+			stringBuilder.WriteString(xmlNamespaces[node.Namespace])
+			stringBuilder.WriteString(`"`)
+		}
+
+		for _, attribute := range node.Attr {
+			key := attribute.Key
+			if attribute.Namespace != "" {
+				key = attribute.Namespace + ":" + key
+			}
+
+			stringBuilder.WriteString(" ")
+			stringBuilder.WriteString(key)
+			stringBuilder.WriteString(`="`)
+			stringBuilder.WriteString(html.EscapeString(attribute.Val))
+			stringBuilder.WriteString(`"`)
+		}
+
+		if node.FirstChild == nil && slices.Contains(voidElements, node.Data) {
+			stringBuilder.WriteString(" />")
+			return nil
+		}
+
+		stringBuilder.WriteString(">")
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		err := renderXhtml(stringBuilder, child)
+		if err != nil {
+			return err
+		}
+	}
+
+	if node.Type == html.ElementNode {
+		stringBuilder.WriteString("</")
+		stringBuilder.WriteString(node.Data)
+		stringBuilder.WriteString(">")
+	}
+
+	return nil
+}
+
+// hasAttribute reports, whether the node has an attribute without namespace with the given key.
+func hasAttribute(node *html.Node, key string) bool {
+	for _, attribute := range node.Attr {
+		if attribute.Namespace == "" && attribute.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// xmlNamespaces are the XML namespaces of the namespaces of the parse tree, e.g. "svg".
+var xmlNamespaces = map[string]string{
+	"":     "http://www.w3.org/1999/xhtml",
+	"svg":  "http://www.w3.org/2000/svg",
+	"math": "http://www.w3.org/1998/Math/MathML",
+}
+
+// voidElements are HTML elements that must not have any content.
+// See: https://html.spec.whatwg.org/multipage/syntax.html#void-elements
+var voidElements = []string{"area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "source", "track", "wbr"}
@@ -0,0 +1,67 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+// Package slug creates URL-friendly names from texts, e.g. "getting-started" from "Getting Started!".
+package slug
+
+import (
+	"strings"
+	"unicode"
+)
+
+// transliterations replace non-ASCII letters with their closest ASCII spelling.
+// German umlauts become two letters, e.g. "ä" becomes "ae", as is common in German URLs.
+var transliterations = map[rune]string{
+	'ä': "ae", 'ö': "oe", 'ü': "ue", 'ß': "ss",
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a", 'æ': "ae",
+	'ç': "c", 'ć': "c", 'č': "c", 'ĉ': "c", 'ċ': "c",
+	'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g", 'ĝ': "g", 'ġ': "g", 'ģ': "g",
+	'ĥ': "h", 'ħ': "h",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'į': "i", 'ı': "i",
+	'ĵ': "j", 'ķ': "k",
+	'ĺ': "l", 'ļ': "l", 'ľ': "l", 'ł': "l",
+	'ñ': "n", 'ń': "n", 'ň': "n", 'ņ': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ø': "o", 'ō': "o", 'ő': "o", 'œ': "oe",
+	'ŕ': "r", 'ř': "r",
+	'ś': "s", 'š': "s", 'ş': "s", 'ŝ': "s", 'ș': "s",
+	'ť': "t", 'ţ': "t", 'ț': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ū': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ŵ': "w", 'ý': "y", 'ÿ': "y", 'ŷ': "y",
+	'ź': "z", 'ž': "z", 'ż': "z",
+}
+
+// Make returns the slug of a text: Letters are transliterated to lower case ASCII, and all
+// other characters except digits become single hyphens, e.g. "Über Größen" becomes
+// "ueber-groessen". Letters without transliteration, e.g. of non-Latin scripts, are kept.
+func Make(text string) string {
+	var stringBuilder strings.Builder
+
+	hyphen := false
+
+	for _, character := range strings.ToLower(text) {
+		replacement, ok := transliterations[character]
+
+		if !ok {
+			if !unicode.IsLetter(character) && !unicode.IsDigit(character) {
+				hyphen = stringBuilder.Len() > 0
+				continue
+			}
+
+			replacement = string(character)
+		}
+
+		if hyphen {
+			stringBuilder.WriteRune('-')
+			hyphen = false
+		}
+
+		stringBuilder.WriteString(replacement)
+	}
+
+	return stringBuilder.String()
+}
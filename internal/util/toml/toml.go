@@ -0,0 +1,528 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+// Package toml parses the subset of TOML used by configuration files: tables, arrays of tables,
+// dotted and quoted keys, strings, integers, floats, booleans, arrays and inline tables.
+// Dates and times are returned as strings.
+// See: https://toml.io/en/v1.0.0
+package toml
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type parser struct {
+	data     string
+	position int
+	line     int
+}
+
+var (
+	integerRegexp = regexp.MustCompile(`^[+-]?(?:0|[1-9](?:_?[0-9])*)$`)
+	floatRegexp   = regexp.MustCompile(`^[+-]?(?:0|[1-9](?:_?[0-9])*)(?:\.[0-9](?:_?[0-9])*)?(?:[eE][+-]?[0-9](?:_?[0-9])*)?$`)
+	dateRegexp    = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}(?:[T ]\d{2}:\d{2}(?::\d{2}(?:\.\d+)?)?(?:Z|[+-]\d{2}:\d{2})?)?$|^\d{2}:\d{2}(?::\d{2}(?:\.\d+)?)?$`)
+)
+
+// Parse returns the TOML document as map, with nested tables as maps and arrays as slices.
+func Parse(data []byte) (map[string]any, error) {
+	p := &parser{data: strings.ReplaceAll(string(data), "\r\n", "\n"), line: 1}
+
+	root := make(map[string]any)
+	current := root
+
+	// defined are the keys of the table headers, e.g. "a\x00b" for [a.b], which must not repeat.
+	defined := make(map[string]bool)
+
+	for {
+		p.skipWhitespace(true)
+
+		if p.isEnd() {
+			return root, nil
+		}
+
+		if p.peek() == '[' {
+			isArray := strings.HasPrefix(p.data[p.position:], "[[")
+
+			if isArray {
+				p.position += 2
+			} else {
+				p.position++
+			}
+
+			keys, err := p.parseKey()
+			if err != nil {
+				return nil, err
+			}
+
+			closing := "]"
+			if isArray {
+				closing = "]]"
+			}
+
+			p.skipWhitespace(false)
+			if !strings.HasPrefix(p.data[p.position:], closing) {
+				return nil, p.errorf("expected '%s' after table name", closing)
+			}
+			p.position += len(closing)
+
+			name := strings.Join(keys, "\x00")
+
+			if isArray {
+				// Every array element starts new sub-tables, e.g. [a.b] after [[a]].
+				for key := range defined {
+					if strings.HasPrefix(key, name+"\x00") {
+						delete(defined, key)
+					}
+				}
+			} else if defined[name] {
+				return nil, p.errorf("duplicate table '%s'", strings.Join(keys, "."))
+			} else {
+				defined[name] = true
+			}
+
+			current, err = getTable(root, keys, isArray)
+			if err != nil {
+				return nil, p.errorf("%s", err)
+			}
+
+			err = p.expectLineEnd()
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		err := p.parseKeyValue(current)
+		if err != nil {
+			return nil, err
+		}
+
+		err = p.expectLineEnd()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parseKeyValue(table map[string]any) error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+
+	p.skipWhitespace(false)
+	if p.isEnd() || p.peek() != '=' {
+		return p.errorf("expected '=' after key '%s'", strings.Join(keys, "."))
+	}
+	p.position++
+	p.skipWhitespace(false)
+
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	parent, err := getTable(table, keys[:len(keys)-1], false)
+	if err != nil {
+		return p.errorf("%s", err)
+	}
+
+	key := keys[len(keys)-1]
+	if _, exists := parent[key]; exists {
+		return p.errorf("duplicate key '%s'", strings.Join(keys, "."))
+	}
+
+	parent[key] = value
+
+	return nil
+}
+
+// parseKey parses a simple, quoted or dotted key, e.g. a, "a b" or a.b.
+func (p *parser) parseKey() ([]string, error) {
+	var keys []string
+
+	for {
+		p.skipWhitespace(false)
+
+		if p.isEnd() {
+			return nil, p.errorf("expected key")
+		}
+
+		switch p.peek() {
+		case '"', '\'':
+			key, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+
+			keys = append(keys, key)
+		default:
+			start := p.position
+			for !p.isEnd() && isBareKeyCharacter(p.peek()) {
+				p.position++
+			}
+
+			if start == p.position {
+				return nil, p.errorf("invalid character '%c' in key", p.peek())
+			}
+
+			keys = append(keys, p.data[start:p.position])
+		}
+
+		p.skipWhitespace(false)
+
+		if p.isEnd() || p.peek() != '.' {
+			return keys, nil
+		}
+
+		p.position++
+	}
+}
+
+func (p *parser) parseValue() (any, error) {
+	if p.isEnd() {
+		return nil, p.errorf("expected value")
+	}
+
+	switch p.peek() {
+	case '"', '\'':
+		return p.parseString()
+	case '[':
+		return p.parseArray()
+	case '{':
+		return p.parseInlineTable()
+	}
+
+	start := p.position
+	for !p.isEnd() && !strings.ContainsRune(",]}#\n", rune(p.peek())) {
+		p.position++
+	}
+
+	token := strings.TrimSpace(p.data[start:p.position])
+	p.position = start + len(token)
+
+	switch {
+	case token == "true":
+		return true, nil
+	case token == "false":
+		return false, nil
+	case integerRegexp.MatchString(token):
+		return strconv.ParseInt(strings.ReplaceAll(token, "_", ""), 10, 64)
+	case floatRegexp.MatchString(token):
+		return strconv.ParseFloat(strings.ReplaceAll(token, "_", ""), 64)
+	case dateRegexp.MatchString(token):
+		return token, nil
+	}
+
+	return nil, p.errorf("invalid value '%s'", token)
+}
+
+func (p *parser) parseString() (string, error) {
+	quote := p.data[p.position : p.position+1]
+	isLiteral := quote == "'"
+
+	// multi-line strings
+	if strings.HasPrefix(p.data[p.position:], strings.Repeat(quote, 3)) {
+		p.position += 3
+
+		// A newline immediately following the opening delimiter is trimmed.
+		if strings.HasPrefix(p.data[p.position:], "\n") {
+			p.position++
+			p.line++
+		}
+
+		end := strings.Index(p.data[p.position:], strings.Repeat(quote, 3))
+		if end < 0 {
+			return "", p.errorf("unterminated multi-line string")
+		}
+
+		value := p.data[p.position : p.position+end]
+		p.line += strings.Count(value, "\n")
+		p.position += end + 3
+
+		if isLiteral {
+			return value, nil
+		}
+
+		return p.unescape(value)
+	}
+
+	p.position++
+	start := p.position
+
+	for ; !p.isEnd(); p.position++ {
+		character := p.peek()
+
+		if character == '\n' {
+			break
+		}
+
+		if character == '\\' && !isLiteral {
+			p.position++
+			continue
+		}
+
+		if string(character) == quote {
+			value := p.data[start:p.position]
+			p.position++
+
+			if isLiteral {
+				return value, nil
+			}
+
+			return p.unescape(value)
+		}
+	}
+
+	return "", p.errorf("unterminated string")
+}
+
+func (p *parser) parseArray() ([]any, error) {
+	values := []any{}
+
+	p.position++ // skip "["
+
+	for {
+		p.skipWhitespace(true)
+
+		if p.isEnd() {
+			return nil, p.errorf("unterminated array")
+		}
+
+		if p.peek() == ']' {
+			p.position++
+			return values, nil
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, value)
+
+		p.skipWhitespace(true)
+
+		if !p.isEnd() && p.peek() == ',' {
+			p.position++
+			continue
+		}
+
+		if p.isEnd() || p.peek() != ']' {
+			return nil, p.errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+func (p *parser) parseInlineTable() (map[string]any, error) {
+	table := make(map[string]any)
+
+	p.position++ // skip "{"
+	p.skipWhitespace(false)
+
+	if !p.isEnd() && p.peek() == '}' {
+		p.position++
+		return table, nil
+	}
+
+	for {
+		err := p.parseKeyValue(table)
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipWhitespace(false)
+
+		if p.isEnd() {
+			return nil, p.errorf("unterminated inline table")
+		}
+
+		switch p.peek() {
+		case ',':
+			p.position++
+		case '}':
+			p.position++
+			return table, nil
+		default:
+			return nil, p.errorf("expected ',' or '}' in inline table")
+		}
+	}
+}
+
+func (p *parser) unescape(value string) (string, error) {
+	var stringBuilder strings.Builder
+
+	for index := 0; index < len(value); index++ {
+		if value[index] != '\\' {
+			stringBuilder.WriteByte(value[index])
+			continue
+		}
+
+		index++
+		if index >= len(value) {
+			return "", p.errorf("invalid escape sequence at end of string")
+		}
+
+		switch value[index] {
+		case 'b':
+			stringBuilder.WriteByte('\b')
+		case 't':
+			stringBuilder.WriteByte('\t')
+		case 'n':
+			stringBuilder.WriteByte('\n')
+		case 'f':
+			stringBuilder.WriteByte('\f')
+		case 'r':
+			stringBuilder.WriteByte('\r')
+		case '"':
+			stringBuilder.WriteByte('"')
+		case '\\':
+			stringBuilder.WriteByte('\\')
+		case 'u', 'U':
+			length := 4
+			if value[index] == 'U' {
+				length = 8
+			}
+
+			if index+length >= len(value) {
+				return "", p.errorf("invalid unicode escape sequence")
+			}
+
+			codePoint, err := strconv.ParseUint(value[index+1:index+1+length], 16, 32)
+			if err != nil {
+				return "", p.errorf("invalid unicode escape sequence")
+			}
+
+			stringBuilder.WriteRune(rune(codePoint))
+			index += length
+		case '\n', ' ', '\t':
+			// line ending backslash: trim all whitespace up to the next non-whitespace character
+			for index+1 < len(value) && strings.ContainsRune(" \t\n", rune(value[index+1])) {
+				index++
+			}
+		default:
+			return "", p.errorf("invalid escape sequence '\\%c'", value[index])
+		}
+	}
+
+	return stringBuilder.String(), nil
+}
+
+// skipWhitespace skips spaces, tabs and comments, and newlines if requested.
+func (p *parser) skipWhitespace(newlines bool) {
+	for !p.isEnd() {
+		switch p.peek() {
+		case ' ', '\t':
+			p.position++
+		case '\n':
+			if !newlines {
+				return
+			}
+
+			p.position++
+			p.line++
+		case '#':
+			if !newlines {
+				return
+			}
+
+			for !p.isEnd() && p.peek() != '\n' {
+				p.position++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) expectLineEnd() error {
+	p.skipWhitespace(false)
+
+	if !p.isEnd() && p.peek() == '#' {
+		for !p.isEnd() && p.peek() != '\n' {
+			p.position++
+		}
+	}
+
+	if p.isEnd() {
+		return nil
+	}
+
+	if p.peek() != '\n' {
+		return p.errorf("expected end of line, found '%c'", p.peek())
+	}
+
+	return nil
+}
+
+func (p *parser) peek() byte {
+	return p.data[p.position]
+}
+
+func (p *parser) isEnd() bool {
+	return p.position >= len(p.data)
+}
+
+func (p *parser) errorf(format string, arguments ...any) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, arguments...))
+}
+
+// getTable returns the nested table for the given keys, creating missing tables.
+// For arrays of tables, a new table is appended to the array.
+func getTable(root map[string]any, keys []string, isArray bool) (map[string]any, error) {
+	current := root
+
+	for index, key := range keys {
+		isLast := index == len(keys)-1
+
+		switch value := current[key].(type) {
+		case nil:
+			table := make(map[string]any)
+
+			if isLast && isArray {
+				current[key] = []any{table}
+			} else {
+				current[key] = table
+			}
+
+			current = table
+		case map[string]any:
+			if isLast && isArray {
+				return nil, fmt.Errorf("key '%s' is a table, not an array of tables", strings.Join(keys, "."))
+			}
+
+			current = value
+		case []any:
+			if len(value) == 0 {
+				return nil, fmt.Errorf("key '%s' is an array, not a table", strings.Join(keys[:index+1], "."))
+			}
+
+			table, ok := value[len(value)-1].(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("key '%s' is an array, not a table", strings.Join(keys[:index+1], "."))
+			}
+
+			if isLast && isArray {
+				table = make(map[string]any)
+				current[key] = append(value, table)
+			}
+
+			current = table
+		default:
+			return nil, fmt.Errorf("key '%s' is a value, not a table", strings.Join(keys[:index+1], "."))
+		}
+	}
+
+	return current, nil
+}
+
+func isBareKeyCharacter(character byte) bool {
+	return character >= 'a' && character <= 'z' || character >= 'A' && character <= 'Z' || character >= '0' && character <= '9' || character == '_' || character == '-'
+}
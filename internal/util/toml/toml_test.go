@@ -0,0 +1,115 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+package toml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		expected map[string]any
+	}{
+		{
+			name:     "key values",
+			document: "title = \"My Book\"\nsplit-level = 2\nratio = 1.5\nstrict = true\ndate = 2023-06-01\n",
+			expected: map[string]any{"title": "My Book", "split-level": int64(2), "ratio": 1.5, "strict": true, "date": "2023-06-01"},
+		},
+		{
+			name:     "dotted and quoted keys",
+			document: "a.b = 1\n\"c d\" = 2\n",
+			expected: map[string]any{"a": map[string]any{"b": int64(1)}, "c d": int64(2)},
+		},
+		{
+			name:     "escapes",
+			document: `text = "tab\there \"quoted\" back\\slash \u00e4 \U0001F600"`,
+			expected: map[string]any{"text": "tab\there \"quoted\" back\\slash ä 😀"},
+		},
+		{
+			name:     "literal string",
+			document: `path = 'C:\books\u00e4'`,
+			expected: map[string]any{"path": `C:\books\u00e4`},
+		},
+		{
+			name:     "multi-line strings",
+			document: "a = \"\"\"\nline 1\nline 2\"\"\"\nb = \"\"\"one \\\n    two\"\"\"\n",
+			expected: map[string]any{"a": "line 1\nline 2", "b": "one two"},
+		},
+		{
+			name:     "arrays and inline tables",
+			document: "formats = [\n  \"html\", # comment\n  \"epub\",\n]\npoint = { x = 1, y = 2 }\n",
+			expected: map[string]any{"formats": []any{"html", "epub"}, "point": map[string]any{"x": int64(1), "y": int64(2)}},
+		},
+		{
+			name:     "tables",
+			document: "[options.epub]\nfile = \"book.epub\"\n\n[options]\nhtml = { search = false }\n",
+			expected: map[string]any{"options": map[string]any{"epub": map[string]any{"file": "book.epub"}, "html": map[string]any{"search": false}}},
+		},
+		{
+			name:     "arrays of tables",
+			document: "[[contributors]]\nname = \"Jane\"\n[contributors.address]\ncity = \"Vienna\"\n\n[[contributors]]\nname = \"John\"\n[contributors.address]\ncity = \"Graz\"\n",
+			expected: map[string]any{"contributors": []any{
+				map[string]any{"name": "Jane", "address": map[string]any{"city": "Vienna"}},
+				map[string]any{"name": "John", "address": map[string]any{"city": "Graz"}},
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := Parse([]byte(test.document))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		expected string
+	}{
+		{"truncated unicode escape", `a = "\u000"`, "line 1: invalid unicode escape sequence"},
+		{"truncated long unicode escape", `a = "\U0001F60"`, "line 1: invalid unicode escape sequence"},
+		{"invalid unicode escape", `a = "\u00zz"`, "line 1: invalid unicode escape sequence"},
+		{"invalid escape", `a = "\q"`, "line 1: invalid escape sequence '\\q'"},
+		{"duplicate table", "[a]\nb = 1\n\n[a]\nc = 2\n", "line 4: duplicate table 'a'"},
+		{"duplicate sub-table", "[[a]]\n[a.b]\n[a.b]\n", "line 3: duplicate table 'a.b'"},
+		{"duplicate key", "a = 1\na = 2\n", "line 2: duplicate key 'a'"},
+		{"table as array of tables", "[a]\n[[a]]\n", "line 2: key 'a' is a table, not an array of tables"},
+		{"value as table", "a = 1\n[a.b]\n", "line 2: key 'a' is a value, not a table"},
+		{"missing equals sign", "\n\na 1\n", "line 3: expected '=' after key 'a'"},
+		{"invalid value", "a = yes\n", "line 1: invalid value 'yes'"},
+		{"unterminated string", "a = \"text\nb = 1\n", "line 1: unterminated string"},
+		{"unterminated array", "a = [1,\n2\n", "line 3: expected ',' or ']' in array"},
+		{"unterminated empty array", "a = [\n", "line 2: unterminated array"},
+		{"unterminated table header", "[a\n", "line 1: expected ']' after table name"},
+		{"trailing characters", "a = \"text\" b\n", "line 1: expected end of line, found 'b'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse([]byte(test.document))
+			if err == nil {
+				t.Fatalf("expected error '%s', got none", test.expected)
+			}
+
+			if err.Error() != test.expected {
+				t.Errorf("expected error '%s', got '%s'", test.expected, err)
+			}
+		})
+	}
+}
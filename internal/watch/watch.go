@@ -0,0 +1,158 @@
+/*
+ * Copyright (C) 2023 Stefan Kühnel
+ *
+ * SPDX-License-Identifier: EUPL-1.2
+ */
+
+// Package watch detects changes of files and directories by polling their modification
+// times and sizes, which works on every platform without dependencies.
+package watch
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fileState is the state of a file used to detect changes.
+type fileState struct {
+	modified time.Time
+	size     int64
+}
+
+// Watcher polls files, directories and glob patterns for changes.
+type Watcher struct {
+	paths    []string
+	excluded []string
+	interval time.Duration
+	debounce time.Duration
+	states   map[string]fileState // key: file name
+}
+
+// New returns a watcher of the given files, directories and glob patterns. Directories are
+// watched recursively, except for the excluded paths, e.g. the output directory.
+func New(paths []string, excluded []string, interval time.Duration, debounce time.Duration) *Watcher {
+	watcher := &Watcher{
+		paths:    paths,
+		interval: interval,
+		debounce: debounce,
+	}
+
+	for _, path := range excluded {
+		if absolute, err := filepath.Abs(path); err == nil {
+			watcher.excluded = append(watcher.excluded, absolute)
+		}
+	}
+
+	watcher.states = watcher.scan()
+
+	return watcher
+}
+
+// Wait blocks until files were changed, created or removed, and no further changes happened
+// within the debounce delay. It returns the names of the changed files in alphabetical order.
+func (w *Watcher) Wait() []string {
+	changed := make(map[string]bool)
+	var lastChange time.Time
+
+	for {
+		time.Sleep(w.interval)
+
+		states := w.scan()
+
+		for _, name := range getChanges(w.states, states) {
+			changed[name] = true
+			lastChange = time.Now()
+		}
+
+		w.states = states
+
+		if len(changed) > 0 && time.Since(lastChange) >= w.debounce {
+			break
+		}
+	}
+
+	var names []string
+	for name := range changed {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// scan returns the current states of all watched files.
+func (w *Watcher) scan() map[string]fileState {
+	states := make(map[string]fileState)
+
+	for _, path := range w.paths {
+		matches, err := filepath.Glob(path)
+		if err != nil || len(matches) == 0 {
+			continue
+		}
+
+		for _, match := range matches {
+			_ = filepath.WalkDir(match, func(name string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return nil // e.g. removed while scanning
+				}
+
+				if entry.IsDir() {
+					if w.isExcluded(name) || (name != match && strings.HasPrefix(entry.Name(), ".")) {
+						return filepath.SkipDir
+					}
+
+					return nil
+				}
+
+				info, err := entry.Info()
+				if err != nil {
+					return nil
+				}
+
+				states[name] = fileState{modified: info.ModTime(), size: info.Size()}
+
+				return nil
+			})
+		}
+	}
+
+	return states
+}
+
+func (w *Watcher) isExcluded(name string) bool {
+	absolute, err := filepath.Abs(name)
+	if err != nil {
+		return false
+	}
+
+	for _, excluded := range w.excluded {
+		if absolute == excluded {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getChanges returns the names of all files, which were changed, created or removed.
+func getChanges(previous map[string]fileState, current map[string]fileState) []string {
+	var names []string
+
+	for name, state := range current {
+		if previousState, ok := previous[name]; !ok || previousState != state {
+			names = append(names, name)
+		}
+	}
+
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}